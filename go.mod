@@ -1,3 +1,3 @@
 module github.com/HayoVanLoon/go-commons
 
-go 1.15
+go 1.23
@@ -0,0 +1,171 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HayoVanLoon/go-commons/logjson"
+)
+
+// A statusWriter records the status and size of the response written
+// through it.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	// informational responses precede the actual one
+	if w.status == 0 && code >= 200 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(bs []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(bs)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		f.Flush()
+	}
+}
+
+// Returns the wrapped ResponseWriter, for http.ResponseController.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Returns middleware that writes an entry to the logger for every request
+// once it has been served. If the logger is a logjson.RequestLogger, like the
+// loggers created by logjson.NewLogger, the entry has the Cloud Logging
+// httpRequest fields filled in and the route pattern as "route" label.
+// Otherwise, only a message is logged, on the level a RequestLogger would use.
+// The trace is taken from the X-Cloud-Trace-Context or traceparent header.
+// Requests whose handler panics are logged with status 500 if nothing was
+// written yet.
+//
+// The remote IP is determined as by ClientIPKey with the given number of
+// trusted proxies, so clients cannot forge it. The X-Forwarded-Proto header is
+// only used when there are trusted proxies. On Cloud Run, use one.
+//
+// Example:
+//   t.Use(AccessLog(logjson.NewDefaultLogger("my-project", "api"), 1))
+func AccessLog(logger logjson.Logger, trustedProxies int) func(http.Handler) http.Handler {
+	rl, ok := logger.(logjson.RequestLogger)
+	if !ok {
+		rl = plainRequestLogger{logger}
+	}
+	clientIP := ClientIPKey(trustedProxies)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := &statusWriter{ResponseWriter: w}
+			done := false
+			defer func() {
+				if !done && sw.status == 0 {
+					sw.status = http.StatusInternalServerError
+				}
+				if sw.status == 0 {
+					sw.status = http.StatusOK
+				}
+				var labels map[string]string
+				if p := RoutePattern(r); p != "" {
+					labels = map[string]string{"route": p}
+				}
+				req := newHTTPRequest(r, clientIP(r), trustedProxies > 0, sw.status, sw.size, time.Since(start))
+				rl.Request(req, traceID(r), labels, "%s %s %d", r.Method, r.URL.RequestURI(), sw.status)
+			}()
+			next.ServeHTTP(sw, r)
+			done = true
+		})
+	}
+}
+
+// Logs requests as messages only, for loggers that are no RequestLogger.
+type plainRequestLogger struct {
+	logjson.Logger
+}
+
+func (l plainRequestLogger) Request(req logjson.HTTPRequest, _ string, _ map[string]string, v ...interface{}) {
+	switch {
+	case req.Status >= 500:
+		l.Error(v...)
+	case req.Status >= 400:
+		l.Warn(v...)
+	default:
+		l.Info(v...)
+	}
+}
+
+func newHTTPRequest(r *http.Request, ip string, forwarded bool, status int, size int64, latency time.Duration) logjson.HTTPRequest {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if p := r.Header.Get("X-Forwarded-Proto"); forwarded && p != "" {
+		scheme = p
+	}
+	req := logjson.HTTPRequest{
+		RequestMethod: r.Method,
+		RequestURL:    scheme + "://" + r.Host + r.URL.RequestURI(),
+		Status:        status,
+		ResponseSize:  size,
+		UserAgent:     r.UserAgent(),
+		RemoteIP:      ip,
+		Referer:       r.Referer(),
+		Latency:       strconv.FormatFloat(latency.Seconds(), 'f', -1, 64) + "s",
+		Protocol:      r.Proto,
+	}
+	if r.ContentLength > 0 {
+		req.RequestSize = r.ContentLength
+	}
+	return req
+}
+
+// Returns the ID of the trace the request belongs to, from the
+// X-Cloud-Trace-Context ("TRACE_ID/SPAN_ID;o=1") or the W3C traceparent
+// ("00-TRACE_ID-SPAN_ID-01") header. Returns an empty string if neither holds
+// one.
+func traceID(r *http.Request) string {
+	if h := r.Header.Get("X-Cloud-Trace-Context"); h != "" {
+		id, _, _ := strings.Cut(h, "/")
+		id, _, _ = strings.Cut(id, ";")
+		if isTraceID(id) {
+			return id
+		}
+	}
+	if h := r.Header.Get("traceparent"); h != "" {
+		xs := strings.Split(h, "-")
+		if len(xs) >= 4 && len(xs[0]) == 2 && xs[0] != "ff" && isTraceID(xs[1]) {
+			return xs[1]
+		}
+	}
+	return ""
+}
+
+// Returns whether the string is a valid trace ID: 32 lower case hexadecimal
+// digits, not all zero.
+func isTraceID(s string) bool {
+	if len(s) != 32 || s == strings.Repeat("0", 32) {
+		return false
+	}
+	for i := 0; i < len(s); i += 1 {
+		if !('0' <= s[i] && s[i] <= '9' || 'a' <= s[i] && s[i] <= 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,233 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/HayoVanLoon/go-commons/logjson"
+)
+
+type requestLog struct {
+	req    logjson.HTTPRequest
+	trace  string
+	labels map[string]string
+}
+
+// Records the requests logged; its other methods are not used.
+type fakeLogger struct {
+	logjson.Logger
+	logged []requestLog
+}
+
+func (l *fakeLogger) Request(req logjson.HTTPRequest, trace string, labels map[string]string, _ ...interface{}) {
+	req.Latency = ""
+	l.logged = append(l.logged, requestLog{req, trace, labels})
+}
+
+func TestAccessLog(t *testing.T) {
+	logger := &fakeLogger{}
+	tr := NewTreeMux()
+	tr.Use(AccessLog(logger, 1))
+	tr.HandleMethodFunc(http.MethodGet, "/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello"))
+	})
+	tr.HandleFunc("/created", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	tr.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("oops")
+	})
+	tr.SetPanicHandler(func(w http.ResponseWriter, r *http.Request, v interface{}) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	cases := []struct {
+		method  string
+		target  string
+		headers map[string]string
+		exp     requestLog
+	}{
+		{
+			http.MethodGet, "/users/42?x=1",
+			map[string]string{
+				"User-Agent":            "test",
+				"X-Cloud-Trace-Context": "0123456789abcdef0123456789abcdef/123;o=1",
+			},
+			requestLog{
+				logjson.HTTPRequest{
+					RequestMethod: "GET",
+					RequestURL:    "http://example.com/users/42?x=1",
+					Status:        200,
+					ResponseSize:  5,
+					UserAgent:     "test",
+					RemoteIP:      "192.0.2.1",
+					Protocol:      "HTTP/1.1",
+				},
+				"0123456789abcdef0123456789abcdef",
+				map[string]string{"route": "/users/{id}"},
+			},
+		},
+		{
+			http.MethodPost, "/created",
+			map[string]string{
+				"X-Forwarded-For":   "10.0.0.1, 10.0.0.2",
+				"X-Forwarded-Proto": "https",
+				"traceparent":       "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			},
+			requestLog{
+				logjson.HTTPRequest{
+					RequestMethod: "POST",
+					RequestURL:    "https://example.com/created",
+					Status:        201,
+					RemoteIP:      "10.0.0.2",
+					Protocol:      "HTTP/1.1",
+				},
+				"4bf92f3577b34da6a3ce929d0e0e4736",
+				map[string]string{"route": "/created"},
+			},
+		},
+		{
+			http.MethodPost, "/users/42", nil,
+			requestLog{
+				logjson.HTTPRequest{
+					RequestMethod: "POST",
+					RequestURL:    "http://example.com/users/42",
+					Status:        405,
+					ResponseSize:  int64(len("Method Not Allowed\n")),
+					RemoteIP:      "192.0.2.1",
+					Protocol:      "HTTP/1.1",
+				},
+				"",
+				map[string]string{"route": "/users/{id}"},
+			},
+		},
+		{
+			http.MethodGet, "/nope", nil,
+			requestLog{
+				logjson.HTTPRequest{
+					RequestMethod: "GET",
+					RequestURL:    "http://example.com/nope",
+					Status:        404,
+					ResponseSize:  int64(len("404 page not found\n")),
+					RemoteIP:      "192.0.2.1",
+					Protocol:      "HTTP/1.1",
+				},
+				"",
+				nil,
+			},
+		},
+		{
+			http.MethodGet, "/panic", nil,
+			requestLog{
+				logjson.HTTPRequest{
+					RequestMethod: "GET",
+					RequestURL:    "http://example.com/panic",
+					Status:        500,
+					RemoteIP:      "192.0.2.1",
+					Protocol:      "HTTP/1.1",
+				},
+				"",
+				map[string]string{"route": "/panic"},
+			},
+		},
+	}
+	for i, c := range cases {
+		logger.logged = nil
+		r := httptest.NewRequest(c.method, c.target, nil)
+		for k, v := range c.headers {
+			r.Header.Set(k, v)
+		}
+		tr.ServeHTTP(httptest.NewRecorder(), r)
+		if len(logger.logged) != 1 {
+			t.Errorf("%v %s: expected 1 entry, got %v", i, c.target, len(logger.logged))
+			continue
+		}
+		if act := logger.logged[0]; !reflect.DeepEqual(act, c.exp) {
+			t.Errorf("%v %s: expected\n%+v, got\n%+v", i, c.target, c.exp, act)
+		}
+	}
+}
+
+func TestAccessLog_Untrusted(t *testing.T) {
+	logger := &fakeLogger{}
+	tr := NewTreeMux()
+	tr.Use(AccessLog(logger, 0))
+	tr.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Forwarded-For", "6.6.6.6")
+	r.Header.Set("X-Forwarded-Proto", "https")
+	tr.ServeHTTP(httptest.NewRecorder(), r)
+	if len(logger.logged) != 1 {
+		t.Fatalf("expected 1 entry, got %v", len(logger.logged))
+	}
+	req := logger.logged[0].req
+	if req.RemoteIP != "192.0.2.1" || req.RequestURL != "http://example.com/" {
+		t.Errorf("expected forwarding headers to be ignored, got %s %s", req.RemoteIP, req.RequestURL)
+	}
+}
+
+// Records the messages logged, by level.
+type plainLogger struct {
+	logjson.Logger
+	logged []string
+}
+
+func (l *plainLogger) Info(v ...interface{}) {
+	l.logged = append(l.logged, "INFO "+fmt.Sprintf(v[0].(string), v[1:]...))
+}
+
+func (l *plainLogger) Warn(v ...interface{}) {
+	l.logged = append(l.logged, "WARNING "+fmt.Sprintf(v[0].(string), v[1:]...))
+}
+
+func (l *plainLogger) Error(v ...interface{}) {
+	l.logged = append(l.logged, "ERROR "+fmt.Sprintf(v[0].(string), v[1:]...))
+}
+
+func TestAccessLog_PlainLogger(t *testing.T) {
+	logger := &plainLogger{}
+	tr := NewTreeMux()
+	tr.Use(AccessLog(logger, 0))
+	tr.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {})
+	tr.HandleFunc("/fail", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	for _, p := range []string{"/ok", "/nope", "/fail"} {
+		tr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, p, nil))
+	}
+	exp := []string{"INFO GET /ok 200", "WARNING GET /nope 404", "ERROR GET /fail 502"}
+	if !reflect.DeepEqual(logger.logged, exp) {
+		t.Errorf("expected %v, got %v", exp, logger.logged)
+	}
+}
+
+func TestTraceID(t *testing.T) {
+	id := "4bf92f3577b34da6a3ce929d0e0e4736"
+	cases := []struct {
+		header string
+		value  string
+		exp    string
+	}{
+		{"X-Cloud-Trace-Context", id + "/1;o=1", id},
+		{"X-Cloud-Trace-Context", id, id},
+		{"X-Cloud-Trace-Context", "abc/1", ""},
+		{"traceparent", "00-" + id + "-00f067aa0ba902b7-01", id},
+		{"traceparent", "ff-" + id + "-00f067aa0ba902b7-01", ""},
+		{"traceparent", "00-" + strings.Repeat("0", 32) + "-00f067aa0ba902b7-01", ""},
+		{"traceparent", "00-" + strings.ToUpper(id) + "-00f067aa0ba902b7-01", ""},
+		{"traceparent", id, ""},
+	}
+	for i, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(c.header, c.value)
+		if act := traceID(r); act != c.exp {
+			t.Errorf("%v: %s: %s expected %q, got %q", i, c.header, c.value, c.exp, act)
+		}
+	}
+}
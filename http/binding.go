@@ -0,0 +1,281 @@
+package http
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// A Validator checks a bound request. Returning Violations yields a
+// 422 Unprocessable Entity listing them, any other error a 400 Bad Request.
+type Validator interface {
+	Validate() error
+}
+
+// Binds the request into v, which must be a non-nil pointer. A JSON body is
+// decoded first, rejecting unknown fields. Then struct fields tagged with
+//   path:"name"    are set from the path parameter
+//   query:"name"   are set from the query parameter; slices take all values
+// Supported field types are strings, booleans, numbers, types implementing
+// encoding.TextUnmarshaler and pointers and (for queries) slices of these.
+// Finally, v is validated when it implements Validator.
+//
+// Errors caused by the request are *Problem. A tagged field of an unsupported
+// type yields another error, which WriteError answers with 500 Internal Server
+// Error.
+func Bind(r *http.Request, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	isStruct := rv.Kind() == reflect.Ptr && rv.Elem().Kind() == reflect.Struct
+	if isStruct {
+		if err := checkBindable(rv.Elem().Type()); err != nil {
+			return err
+		}
+	}
+	if err := bindBody(r, v); err != nil {
+		return err
+	}
+	var vs Violations
+	if isStruct {
+		vs = bindValues(r, rv.Elem())
+	}
+	if len(vs) > 0 {
+		p := NewProblem(http.StatusBadRequest, "invalid parameters")
+		p.Violations = vs
+		return p
+	}
+	if val, ok := v.(Validator); ok {
+		if err := val.Validate(); err != nil {
+			var vs Violations
+			if errors.As(err, &vs) {
+				return ProblemFor(vs)
+			}
+			return NewProblem(http.StatusBadRequest, err.Error())
+		}
+	}
+	return nil
+}
+
+func bindBody(r *http.Request, v interface{}) error {
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		return nil
+	}
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mt, _, err := mime.ParseMediaType(ct)
+		if err != nil || (mt != "application/json" && !strings.HasSuffix(mt, "+json")) {
+			return NewProblem(http.StatusUnsupportedMediaType, "expected application/json")
+		}
+	}
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(v)
+	if err == io.EOF {
+		return nil
+	}
+	if err == nil && dec.More() {
+		err = errors.New("unexpected data after JSON value")
+	}
+	if err != nil {
+		var mbe *http.MaxBytesError
+		if errors.As(err, &mbe) {
+			return ProblemFor(mbe)
+		}
+		return NewProblem(http.StatusBadRequest, "invalid JSON body: "+err.Error())
+	}
+	return nil
+}
+
+func bindValues(r *http.Request, v reflect.Value) Violations {
+	var vs Violations
+	var query map[string][]string
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		if name, ok := f.Tag.Lookup("path"); ok {
+			s, found := pathParam(r, name)
+			if !found {
+				continue
+			}
+			if err := setValue(v.Field(i), s); err != nil {
+				vs = append(vs, Violation{Field: name, Message: err.Error()})
+			}
+		}
+		if name, ok := f.Tag.Lookup("query"); ok {
+			if query == nil {
+				query = r.URL.Query()
+			}
+			xs, found := query[name]
+			if !found {
+				continue
+			}
+			if err := setValues(v.Field(i), xs); err != nil {
+				vs = append(vs, Violation{Field: name, Message: err.Error()})
+			}
+		}
+	}
+	return vs
+}
+
+// Checks whether the fields of the struct type tagged with "path" or "query"
+// can be bound.
+func checkBindable(t reflect.Type) error {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		if name, ok := f.Tag.Lookup("path"); ok && !settable(f.Type) {
+			return fmt.Errorf("cannot bind path parameter %q to field %s of type %s", name, f.Name, f.Type)
+		}
+		if name, ok := f.Tag.Lookup("query"); ok && !settable(f.Type) && !settableSlice(f.Type) {
+			return fmt.Errorf("cannot bind query parameter %q to field %s of type %s", name, f.Name, f.Type)
+		}
+	}
+	return nil
+}
+
+// Returns whether setValue can set a value of the type.
+func settable(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		return settable(t.Elem())
+	}
+	if reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// Returns whether setValues can set a slice of the type element by element.
+func settableSlice(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && settable(t.Elem())
+}
+
+func pathParam(r *http.Request, name string) (string, bool) {
+	for _, p := range params(r) {
+		if p.Name == name {
+			return p.Value, true
+		}
+	}
+	return "", false
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+func setValues(v reflect.Value, xs []string) error {
+	if v.Kind() != reflect.Slice || reflect.PtrTo(v.Type()).Implements(textUnmarshalerType) {
+		return setValue(v, xs[len(xs)-1])
+	}
+	s := reflect.MakeSlice(v.Type(), len(xs), len(xs))
+	for i, x := range xs {
+		if err := setValue(s.Index(i), x); err != nil {
+			return err
+		}
+	}
+	v.Set(s)
+	return nil
+}
+
+func setValue(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Ptr {
+		p := reflect.New(v.Type().Elem())
+		if err := setValue(p.Elem(), s); err != nil {
+			return err
+		}
+		v.Set(p)
+		return nil
+	}
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		if err := u.UnmarshalText([]byte(s)); err != nil {
+			return fmt.Errorf("invalid value %q", s)
+		}
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return errors.New("must be a boolean")
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return errors.New("must be an integer")
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return errors.New("must be a non-negative integer")
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return errors.New("must be a number")
+		}
+		v.SetFloat(n)
+	default:
+		// ruled out by checkBindable
+		panic(fmt.Sprintf("cannot bind to field of type %s", v.Type()))
+	}
+	return nil
+}
+
+// Returns a handler that binds the request into a Req (see Bind), calls fn
+// and writes the response as JSON. Errors are written as problems (see
+// ProblemFor). The status is 200 OK, unless the response has a method
+//   StatusCode() int
+// A response of type NoContent yields a 204 No Content.
+//
+// Panics when Req is a struct with a tagged field that cannot be bound.
+func JSONHandler[Req, Resp any](fn func(ctx context.Context, req Req) (Resp, error)) http.Handler {
+	if t := reflect.TypeOf((*Req)(nil)).Elem(); t.Kind() == reflect.Struct {
+		if err := checkBindable(t); err != nil {
+			panic(err)
+		}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req Req
+		if err := Bind(r, &req); err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		resp, err := fn(r.Context(), req)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		switch x := interface{}(resp).(type) {
+		case NoContent:
+			w.WriteHeader(http.StatusNoContent)
+		case interface{ StatusCode() int }:
+			WriteJSON(w, x.StatusCode(), resp)
+		default:
+			WriteJSON(w, http.StatusOK, resp)
+		}
+	})
+}
+
+// NoContent can be used as response type for handlers without a response
+// body.
+type NoContent struct{}
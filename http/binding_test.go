@@ -0,0 +1,255 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type bindItem struct {
+	ID     int64     `path:"id" json:"-"`
+	Tags   []string  `query:"tag" json:"-"`
+	Limit  *int      `query:"limit" json:"-"`
+	Since  time.Time `query:"since" json:"-"`
+	Name   string    `json:"name"`
+	Amount float64   `json:"amount"`
+}
+
+func (b bindItem) Validate() error {
+	var vs Violations
+	if b.Amount < 0 {
+		vs = append(vs, Violation{"amount", "must not be negative"})
+	}
+	if len(vs) > 0 {
+		return vs
+	}
+	return nil
+}
+
+type created struct {
+	ID int64 `json:"id"`
+}
+
+func (created) StatusCode() int {
+	return http.StatusCreated
+}
+
+func TestBind(t *testing.T) {
+	limit := 5
+	cases := []struct {
+		path   string
+		ct     string
+		body   string
+		exp    bindItem
+		status int
+		fields []string
+	}{
+		{"/items/1", "", "", bindItem{ID: 1}, 0, nil},
+		{"/items/1", "application/json", `{"name":"a","amount":1.5}`, bindItem{ID: 1, Name: "a", Amount: 1.5}, 0, nil},
+		{"/items/2?tag=a&tag=b&limit=5&since=2021-01-02T00:00:00Z", "", "",
+			bindItem{ID: 2, Tags: []string{"a", "b"}, Limit: &limit, Since: time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)}, 0, nil},
+		{"/items/x?limit=y&since=z", "", "", bindItem{}, http.StatusBadRequest, []string{"id", "limit", "since"}},
+		{"/items/1", "application/json", `{"name":"a","colour":"red"}`, bindItem{}, http.StatusBadRequest, nil},
+		{"/items/1", "application/json", `{"name":"a"} {}`, bindItem{}, http.StatusBadRequest, nil},
+		{"/items/1", "application/json", `{"name":`, bindItem{}, http.StatusBadRequest, nil},
+		{"/items/1", "text/plain", `{"name":"a"}`, bindItem{}, http.StatusUnsupportedMediaType, nil},
+		{"/items/1", "application/json", `{"amount":-1}`, bindItem{}, http.StatusUnprocessableEntity, []string{"amount"}},
+	}
+	for i, c := range cases {
+		var act bindItem
+		var err error
+		tr := NewTreeMux()
+		tr.HandleFunc("/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+			err = Bind(r, &act)
+		})
+		r := httptest.NewRequest(http.MethodPost, c.path, strings.NewReader(c.body))
+		if c.ct != "" {
+			r.Header.Set("Content-Type", c.ct)
+		}
+		tr.ServeHTTP(httptest.NewRecorder(), r)
+
+		if c.status == 0 {
+			if err != nil {
+				t.Errorf("%v: unexpected error %v", i, err)
+			} else if !reflect.DeepEqual(act, c.exp) {
+				t.Errorf("%v: expected %+v, got %+v", i, c.exp, act)
+			}
+			continue
+		}
+		var p *Problem
+		if !errors.As(err, &p) {
+			t.Errorf("%v: expected problem, got %v", i, err)
+			continue
+		}
+		if p.Status != c.status {
+			t.Errorf("%v: expected status %v, got %v", i, c.status, p.Status)
+		}
+		var fields []string
+		for _, v := range p.Violations {
+			fields = append(fields, v.Field)
+		}
+		if !reflect.DeepEqual(fields, c.fields) {
+			t.Errorf("%v: expected violations for %v, got %v", i, c.fields, p.Violations)
+		}
+	}
+}
+
+func TestBind_MaxBodySize(t *testing.T) {
+	var err error
+	tr := NewTreeMux()
+	tr.SetLimits("/", Limits{MaxBodySize: 8})
+	tr.HandleFunc("/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		var b bindItem
+		err = Bind(r, &b)
+	})
+	r := httptest.NewRequest(http.MethodPost, "/items/1", strings.NewReader(`{"name":"a long name"}`))
+	r.ContentLength = -1
+	tr.ServeHTTP(httptest.NewRecorder(), r)
+	if p := ProblemFor(err); p.Status != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status %v, got %v (%v)", http.StatusRequestEntityTooLarge, p.Status, err)
+	}
+}
+
+type badPath struct {
+	IDs []string `path:"ids"`
+}
+
+type badQuery struct {
+	Filter map[string]string `query:"filter"`
+}
+
+func TestBind_Unsupported(t *testing.T) {
+	for i, v := range []interface{}{&badPath{}, &badQuery{}} {
+		r := httptest.NewRequest(http.MethodGet, "/?filter=x", nil)
+		err := Bind(r, v)
+		if err == nil {
+			t.Errorf("%v: expected error", i)
+			continue
+		}
+		if p := ProblemFor(err); p.Status != http.StatusInternalServerError {
+			t.Errorf("%v: expected status 500, got %v", i, p.Status)
+		}
+	}
+}
+
+func TestJSONHandler_Unsupported(t *testing.T) {
+	handlers := []func(){
+		func() {
+			JSONHandler(func(ctx context.Context, req badPath) (NoContent, error) { return NoContent{}, nil })
+		},
+		func() {
+			JSONHandler(func(ctx context.Context, req badQuery) (NoContent, error) { return NoContent{}, nil })
+		},
+	}
+	for i, h := range handlers {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%v: expected panic", i)
+				}
+			}()
+			h()
+		}()
+	}
+}
+
+func TestJSONHandler(t *testing.T) {
+	tr := NewTreeMux()
+	tr.HandleMethod(http.MethodGet, "/items/{id}", JSONHandler(func(ctx context.Context, req bindItem) (bindItem, error) {
+		switch req.ID {
+		case 404:
+			return bindItem{}, fmt.Errorf("item %d: %w", req.ID, ErrNotFound)
+		case 500:
+			return bindItem{}, errors.New("database on fire")
+		case 503:
+			return bindItem{}, context.DeadlineExceeded
+		}
+		return bindItem{Name: "item"}, nil
+	}))
+	tr.HandleMethod(http.MethodPost, "/items", JSONHandler(func(ctx context.Context, req bindItem) (created, error) {
+		return created{ID: 7}, nil
+	}))
+	tr.HandleMethod(http.MethodDelete, "/items/{id}", JSONHandler(func(ctx context.Context, req bindItem) (NoContent, error) {
+		return NoContent{}, nil
+	}))
+
+	cases := []struct {
+		method string
+		path   string
+		body   string
+		status int
+		ct     string
+		exp    string
+	}{
+		{http.MethodGet, "/items/1", "", http.StatusOK, "application/json", `{"name":"item","amount":0}`},
+		{http.MethodPost, "/items", `{"name":"a"}`, http.StatusCreated, "application/json", `{"id":7}`},
+		{http.MethodDelete, "/items/1", "", http.StatusNoContent, "", ""},
+		{http.MethodGet, "/items/404", "", http.StatusNotFound, "application/problem+json",
+			`{"title":"Not Found","status":404,"detail":"item 404: Not Found","instance":"/items/404"}`},
+		{http.MethodGet, "/items/500", "", http.StatusInternalServerError, "application/problem+json",
+			`{"title":"Internal Server Error","status":500,"instance":"/items/500"}`},
+		{http.MethodGet, "/items/503", "", http.StatusServiceUnavailable, "application/problem+json",
+			`{"title":"Service Unavailable","status":503,"instance":"/items/503"}`},
+		{http.MethodGet, "/items/x", "", http.StatusBadRequest, "application/problem+json",
+			`{"title":"Bad Request","status":400,"detail":"invalid parameters","instance":"/items/x","violations":[{"field":"id","message":"must be an integer"}]}`},
+		{http.MethodPost, "/items", `{"amount":-2}`, http.StatusUnprocessableEntity, "application/problem+json",
+			`{"title":"Unprocessable Entity","status":422,"instance":"/items","violations":[{"field":"amount","message":"must not be negative"}]}`},
+	}
+	for i, c := range cases {
+		w := httptest.NewRecorder()
+		tr.ServeHTTP(w, httptest.NewRequest(c.method, c.path, strings.NewReader(c.body)))
+		if w.Code != c.status {
+			t.Errorf("%v: expected status %v, got %v", i, c.status, w.Code)
+		}
+		if act := w.Header().Get("Content-Type"); act != c.ct {
+			t.Errorf("%v: expected content type %q, got %q", i, c.ct, act)
+		}
+		if act := strings.TrimSpace(w.Body.String()); act != c.exp {
+			t.Errorf("%v: expected %s, got %s", i, c.exp, act)
+		}
+	}
+}
+
+func TestProblemFor(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+		detail string
+	}{
+		{NewProblem(http.StatusTeapot, "short and stout"), http.StatusTeapot, "short and stout"},
+		{fmt.Errorf("user 1: %w", ErrForbidden), http.StatusForbidden, "user 1: Forbidden"},
+		{Violations{{"a", "b"}}, http.StatusUnprocessableEntity, ""},
+		{&http.MaxBytesError{Limit: 1}, http.StatusRequestEntityTooLarge, "http: request body too large"},
+		{fmt.Errorf("query: %w", context.DeadlineExceeded), http.StatusServiceUnavailable, ""},
+		{errors.New("secret"), http.StatusInternalServerError, ""},
+	}
+	for i, c := range cases {
+		p := ProblemFor(c.err)
+		if p.Status != c.status || p.Detail != c.detail {
+			t.Errorf("%v: expected %v %q, got %v %q", i, c.status, c.detail, p.Status, p.Detail)
+		}
+	}
+	if ErrForbidden.Detail != "" {
+		t.Errorf("sentinel problem was modified")
+	}
+}
+
+func TestWriteProblem(t *testing.T) {
+	w := httptest.NewRecorder()
+	WriteProblem(w, &Problem{Type: "https://example.com/out-of-credit", Status: http.StatusForbidden})
+	var p Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	exp := Problem{Type: "https://example.com/out-of-credit", Title: "Forbidden", Status: http.StatusForbidden}
+	if !reflect.DeepEqual(p, exp) {
+		t.Errorf("expected %+v, got %+v", exp, p)
+	}
+}
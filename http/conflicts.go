@@ -0,0 +1,163 @@
+package http
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/HayoVanLoon/go-commons/pathtrie"
+)
+
+// A ConflictError describes two routes that both match some request path,
+// without one of them being more specific than the other for all such paths.
+// Routes matching exactly the same paths are duplicates.
+type ConflictError struct {
+	Pattern   string
+	Other     string
+	Duplicate bool
+}
+
+func (e *ConflictError) Error() string {
+	if e.Duplicate {
+		return fmt.Sprintf("route %q duplicates %q", e.Pattern, e.Other)
+	}
+	return fmt.Sprintf("route %q conflicts with %q", e.Pattern, e.Other)
+}
+
+// Element kinds, for conflict detection.
+const (
+	elemLiteral = iota
+	elemConstrained
+	elemSingle
+	elemWildcard
+	elemRest
+)
+
+func elemKind(k string) int {
+	if _, ok := pathtrie.CatchAllName(k); ok {
+		return elemRest
+	}
+	if pathtrie.ParamConstraint(k) != "" {
+		return elemConstrained
+	}
+	if _, ok := pathtrie.ParamName(k); ok {
+		return elemSingle
+	}
+	if k == pathtrie.Wildcard {
+		return elemWildcard
+	}
+	return elemLiteral
+}
+
+// Returns whether the literal element satisfies the constraint of element k.
+func accepts(k, literal string) bool {
+	c, err := pathtrie.CompileConstraint(pathtrie.ParamConstraint(k))
+	if err != nil {
+		return false
+	}
+	_, ok := c.Convert(literal)
+	return ok
+}
+
+// Returns whether some element is matched by both x and y. Different
+// constraints are assumed to have values in common.
+func overlapsElem(x, y string) bool {
+	kx, ky := elemKind(x), elemKind(y)
+	switch {
+	case kx == elemLiteral && ky == elemLiteral:
+		return x == y
+	case kx == elemLiteral && ky == elemConstrained:
+		return accepts(y, x)
+	case kx == elemConstrained && ky == elemLiteral:
+		return accepts(x, y)
+	}
+	return true
+}
+
+// Returns whether every element matched by y is also matched by x. Unlike a
+// named element, the wildcard also matches empty elements.
+func coversElem(x, y string) bool {
+	kx, ky := elemKind(x), elemKind(y)
+	switch kx {
+	case elemLiteral:
+		return ky == elemLiteral && x == y
+	case elemConstrained:
+		switch ky {
+		case elemLiteral:
+			return accepts(x, y)
+		case elemConstrained:
+			return pathtrie.ParamConstraint(x) == pathtrie.ParamConstraint(y)
+		}
+		return false
+	case elemSingle:
+		return ky != elemWildcard
+	}
+	return true
+}
+
+// Checks two patterns for a conflict. Returns nil if either one is more
+// specific than the other or when they have no paths in common.
+// Routes with a host pattern only conflict with each other, if their host
+// patterns have as many labels.
+func checkConflict(p, q string) *ConflictError {
+	hp, hq := hostOf(p), hostOf(q)
+	if (hp == "") != (hq == "") || strings.Count(hp, ".") != strings.Count(hq, ".") {
+		return nil
+	}
+	xs, ys := elements(p), elements(q)
+	if !overlaps(xs, ys) {
+		return nil
+	}
+	sub, super := covers(ys, xs), covers(xs, ys)
+	if sub && super {
+		return &ConflictError{Pattern: p, Other: q, Duplicate: true}
+	}
+	if sub || super {
+		return nil
+	}
+	return &ConflictError{Pattern: p, Other: q}
+}
+
+// Returns the host pattern of a pattern, if any.
+func hostOf(p string) string {
+	host, _ := splitHost(p)
+	return host
+}
+
+// Returns the elements of the pattern, including its host labels.
+func elements(p string) []string {
+	if host, path := splitHost(p); host != "" {
+		return strings.Split(hostKey(host, path), "/")
+	}
+	return strings.Split(p, "/")
+}
+
+// Returns whether there is a path matched by both element lists.
+func overlaps(xs, ys []string) bool {
+	if len(xs) > 0 && elemKind(xs[0]) == elemRest || len(ys) > 0 && elemKind(ys[0]) == elemRest {
+		return true
+	}
+	if len(xs) == 0 || len(ys) == 0 {
+		return len(xs) == len(ys)
+	}
+	if !overlapsElem(xs[0], ys[0]) {
+		return false
+	}
+	return overlaps(xs[1:], ys[1:])
+}
+
+// Returns whether every path matched by ys is also matched by xs.
+func covers(xs, ys []string) bool {
+	if len(xs) > 0 && elemKind(xs[0]) == elemRest {
+		return true
+	}
+	if len(ys) > 0 && elemKind(ys[0]) == elemRest {
+		return false
+	}
+	if len(xs) == 0 || len(ys) == 0 {
+		return len(xs) == len(ys)
+	}
+	if !coversElem(xs[0], ys[0]) {
+		return false
+	}
+	return covers(xs[1:], ys[1:])
+}
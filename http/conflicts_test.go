@@ -0,0 +1,62 @@
+package http
+
+import (
+	"testing"
+)
+
+func TestCheckConflict(t *testing.T) {
+	cases := []struct {
+		left      string
+		right     string
+		conflict  bool
+		duplicate bool
+	}{
+		{"/a/b", "/a/b", true, true},
+		{"/a/b", "/a/c", false, false},
+		{"/a/b", "/a/*", false, false},
+		{"/a/{x}", "/a/*", false, false},
+		{"/a/{x}/{y}", "/a/*/c", true, false},
+		{"/a/{x}", "/a/{y}", true, true},
+		{"/a/*/c", "/a/b/*", true, false},
+		{"/a/{x}/c", "/a/b/{y}", true, false},
+		{"/a/*/c", "/a/b/d", false, false},
+		{"/a/*", "/a/*/c", false, false},
+		{"/a/**", "/a/*/c", false, false},
+		{"/a/**", "/a", false, false},
+		{"/a/**", "/a/{rest...}", true, true},
+		{"/a/*/**", "/a/b/c", false, false},
+		{"/a/*/**", "/a/b/{x}/c", false, false},
+		{"/*/b/**", "/a/*/**", true, false},
+		{"/a/b/**", "/*/b/c", true, false},
+		{"/a", "/a/", false, false},
+		{"/a/{id:int}", "/a/{x}", false, false},
+		{"/a/{id:int}", "/a/{x:int}", true, true},
+		{"/a/{id:int}", "/a/{x:[0-9]+}", true, false},
+		{"/a/{id:int}", "/a/42", false, false},
+		{"/a/{id:int}", "/a/b", false, false},
+		{"/a/{id:int}/b", "/a/42/*", true, false},
+		{"/a/{id:int}/b", "/a/x/*", false, false},
+		{"a.example.com/a", "/a", false, false},
+		{"a.example.com/a", "b.example.com/a", false, false},
+		{"a.example.com/a", "{x}.example.com/a", false, false},
+		{"{x}.example.com/a", "{y}.example.com/a", true, true},
+		{"*.example.com/a", "{x}.example.com/a", false, false},
+		{"*.example.com/a", "a.*.com/a", true, false},
+		{"a.example.com/*/b", "{x}.example.com/a/*", true, false},
+		{"{x}.example.com/a", "example.com/a", false, false},
+		{"{x}.example.com/**", "example.com/**", false, false},
+		{"{x}.example/com/a", "{y}.example.com/a", false, false},
+	}
+	for i, c := range cases {
+		for _, args := range [][2]string{{c.left, c.right}, {c.right, c.left}} {
+			err := checkConflict(args[0], args[1])
+			if (err != nil) != c.conflict {
+				t.Errorf("%v: %s, %s expected conflict %v, got %v", i, args[0], args[1], c.conflict, err)
+				continue
+			}
+			if err != nil && err.Duplicate != c.duplicate {
+				t.Errorf("%v: %s, %s expected duplicate %v, got %v", i, args[0], args[1], c.duplicate, err.Duplicate)
+			}
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/HayoVanLoon/go-commons/logjson"
+)
+
+// An Outcome describes how a TreeMux routed a request. It is available to the
+// not-found, method-not-allowed and panic handlers through RouteOutcome.
+type Outcome struct {
+	// the pattern of the route matching the request, empty if there was none
+	Pattern string
+	// the prefix of the not-found handler serving the request, like "/" for
+	// the root handler or "/api/" for that of the group "/api"
+	Prefix string
+	// the methods the route supports, when it did not support the request's
+	Allowed []string
+}
+
+// Returns the outcome of routing the request. Returns false if the request is
+// not being served by a not-found, method-not-allowed or panic handler of a
+// TreeMux.
+func RouteOutcome(r *http.Request) (Outcome, bool) {
+	o, ok := r.Context().Value(outcomeKey).(Outcome)
+	return o, ok
+}
+
+func withOutcome(r *http.Request, o Outcome) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), outcomeKey, o))
+}
+
+// The handlers for requests that cannot be served normally. Like the muxState,
+// they are replaced as a whole.
+type errorHandlers struct {
+	methodNotAllowed http.Handler
+	panic            func(w http.ResponseWriter, r *http.Request, v interface{})
+}
+
+func defaultMethodNotAllowed(w http.ResponseWriter, _ *http.Request) {
+	http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+}
+
+// Logs the panic with its stack trace and responds with 500 Internal Server
+// Error.
+func defaultPanicHandler(w http.ResponseWriter, r *http.Request, v interface{}) {
+	logjson.Error("panic serving %s %s: %v\n%s", r.Method, r.URL.Path, v, debug.Stack())
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
+
+// Logs a panic after the response has started and aborts the request, as it
+// can no longer be answered with an error.
+func abortPanic(r *http.Request, v interface{}) {
+	logjson.Error("panic serving %s %s after response started: %v\n%s", r.Method, r.URL.Path, v, debug.Stack())
+	panic(http.ErrAbortHandler)
+}
@@ -0,0 +1,181 @@
+package http
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// FSOptions configure how a file system is served.
+type FSOptions struct {
+	// The file served for directories. Defaults to "index.html".
+	Index string
+	// Whether paths without a file extension that match no file are served
+	// the index file of the root, as single-page applications expect.
+	SPA bool
+	// Whether directories without index file are listed. If not, they are not
+	// found.
+	Listing bool
+}
+
+// Returns a handler serving the files in the file system, like an embed.FS or
+// an os.DirFS. It is meant for a route ending in a catch-all, whose remainder
+// is the name of the file. Content types are derived from the file extension
+// or, failing that, the contents. Responses carry an ETag and, if the file
+// system provides modification times, a Last-Modified header, so conditional
+// requests are answered with 304 Not Modified. Requests for directories
+// without trailing slash are redirected to add it.
+func FileServer(fsys fs.FS, opts FSOptions) http.Handler {
+	if opts.Index == "" {
+		opts.Index = "index.html"
+	}
+	return &fileServer{fsys: fsys, opts: opts}
+}
+
+type fileServer struct {
+	fsys fs.FS
+	opts FSOptions
+
+	// content hashes of files without modification time, by name
+	etags sync.Map
+}
+
+func (s *fileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(path.Clean("/"+PathRemainder(r)), "/")
+	if name == "" {
+		name = "."
+	}
+	if !fs.ValidPath(name) {
+		http.NotFound(w, r)
+		return
+	}
+
+	fi, err := fs.Stat(s.fsys, name)
+	if errors.Is(err, fs.ErrNotExist) && s.opts.SPA && path.Ext(name) == "" {
+		name, fi, err = s.opts.Index, nil, nil
+	} else if err == nil && fi.IsDir() {
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			u := url.URL{Path: r.URL.Path + "/", RawQuery: r.URL.RawQuery}
+			http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+			return
+		}
+		index := path.Join(name, s.opts.Index)
+		if _, err := fs.Stat(s.fsys, index); err == nil {
+			name = index
+		} else if s.opts.Listing {
+			s.list(w, r, name)
+			return
+		} else {
+			http.NotFound(w, r)
+			return
+		}
+	}
+	if err != nil {
+		s.error(w, r, err)
+		return
+	}
+	s.serveFile(w, r, name)
+}
+
+func (s *fileServer) error(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		http.NotFound(w, r)
+	case errors.Is(err, fs.ErrPermission):
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+	default:
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+func (s *fileServer) serveFile(w http.ResponseWriter, r *http.Request, name string) {
+	f, err := s.fsys.Open(name)
+	if err != nil {
+		s.error(w, r, err)
+		return
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	fi, err := f.Stat()
+	if err != nil {
+		s.error(w, r, err)
+		return
+	}
+	if fi.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+	rs, ok := f.(io.ReadSeeker)
+	if !ok {
+		bs, err := io.ReadAll(f)
+		if err != nil {
+			s.error(w, r, err)
+			return
+		}
+		rs = bytes.NewReader(bs)
+	}
+	etag, err := s.etag(name, fi, rs)
+	if err != nil {
+		s.error(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	http.ServeContent(w, r, fi.Name(), fi.ModTime(), rs)
+}
+
+// Returns the ETag for the file. It is derived from the modification time and
+// size if available, or else from the contents, which are then assumed not to
+// change.
+func (s *fileServer) etag(name string, fi fs.FileInfo, rs io.ReadSeeker) (string, error) {
+	if !fi.ModTime().IsZero() {
+		return fmt.Sprintf(`W/"%x-%x"`, fi.ModTime().UnixNano(), fi.Size()), nil
+	}
+	if etag, ok := s.etags.Load(name); ok {
+		return etag.(string), nil
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, rs); err != nil {
+		return "", err
+	}
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	etag := `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+	s.etags.Store(name, etag)
+	return etag, nil
+}
+
+// Writes a simple HTML listing of the directory.
+func (s *fileServer) list(w http.ResponseWriter, r *http.Request, name string) {
+	es, err := fs.ReadDir(s.fsys, name)
+	if err != nil {
+		s.error(w, r, err)
+		return
+	}
+	sort.Slice(es, func(i, j int) bool {
+		return es[i].Name() < es[j].Name()
+	})
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = fmt.Fprintln(w, "<pre>")
+	for _, e := range es {
+		n := e.Name()
+		if e.IsDir() {
+			n += "/"
+		}
+		u := url.URL{Path: n}
+		_, _ = fmt.Fprintf(w, "<a href=\"%s\">%s</a>\n", html.EscapeString(u.String()), html.EscapeString(n))
+	}
+	_, _ = fmt.Fprintln(w, "</pre>")
+}
@@ -0,0 +1,112 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestTreeMux_ServeFS(t *testing.T) {
+	mod := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"index.html":      {Data: []byte("<p>root</p>")},
+		"app.js":          {Data: []byte("let x = 1;")},
+		"css/a.css":       {Data: []byte("p {}"), ModTime: mod},
+		"docs/index.txt":  {Data: []byte("not an index")},
+		"blog/index.html": {Data: []byte("<p>blog</p>")},
+	}
+
+	tr := NewTreeMux()
+	tr.ServeFS("/static", fsys)
+	tr.Group("/app").ServeFSWithOptions("/", fsys, FSOptions{SPA: true})
+	tr.ServeFSWithOptions("/list", fsys, FSOptions{Listing: true})
+
+	cases := []struct {
+		method      string
+		path        string
+		code        int
+		contentType string
+		body        string
+		location    string
+	}{
+		{http.MethodGet, "/static/", 200, "text/html; charset=utf-8", "<p>root</p>", ""},
+		{http.MethodGet, "/static", 301, "", "", "/static/"},
+		{http.MethodGet, "/static/app.js", 200, "text/javascript; charset=utf-8", "let x = 1;", ""},
+		{http.MethodGet, "/static/css/a.css", 200, "text/css; charset=utf-8", "p {}", ""},
+		{http.MethodHead, "/static/css/a.css", 200, "text/css; charset=utf-8", "", ""},
+		{http.MethodGet, "/static/css/../app.js", 200, "text/javascript; charset=utf-8", "let x = 1;", ""},
+		{http.MethodGet, "/static/blog?x=1", 301, "", "", "/static/blog/?x=1"},
+		{http.MethodGet, "/static/blog/", 200, "text/html; charset=utf-8", "<p>blog</p>", ""},
+		{http.MethodGet, "/static/docs/", 404, "", "", ""},
+		{http.MethodGet, "/static/nope", 404, "", "", ""},
+		{http.MethodPost, "/static/app.js", 405, "", "", ""},
+		{http.MethodGet, "/app/some/route", 200, "text/html; charset=utf-8", "<p>root</p>", ""},
+		{http.MethodGet, "/app/app.js", 200, "text/javascript; charset=utf-8", "let x = 1;", ""},
+		{http.MethodGet, "/app/nope.js", 404, "", "", ""},
+		{http.MethodGet, "/list/docs/", 200, "text/html; charset=utf-8", "<pre>\n<a href=\"index.txt\">index.txt</a>\n</pre>\n", ""},
+	}
+	for i, c := range cases {
+		w := httptest.NewRecorder()
+		tr.ServeHTTP(w, httptest.NewRequest(c.method, c.path, nil))
+		if w.Code != c.code {
+			t.Errorf("%v %s %s: expected %v, got %v", i, c.method, c.path, c.code, w.Code)
+			continue
+		}
+		if w.Header().Get("Location") != c.location {
+			t.Errorf("%v %s %s: expected location %s, got %s", i, c.method, c.path, c.location, w.Header().Get("Location"))
+		}
+		if c.code != 200 {
+			continue
+		}
+		if ct := w.Header().Get("Content-Type"); ct != c.contentType {
+			t.Errorf("%v %s %s: expected content type %s, got %s", i, c.method, c.path, c.contentType, ct)
+		}
+		if w.Body.String() != c.body {
+			t.Errorf("%v %s %s: expected %q, got %q", i, c.method, c.path, c.body, w.Body.String())
+		}
+	}
+}
+
+func TestTreeMux_ServeFS_Conditional(t *testing.T) {
+	mod := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"embedded.txt": {Data: []byte("abc")},
+		"disk.txt":     {Data: []byte("abc"), ModTime: mod},
+	}
+	tr := NewTreeMux()
+	tr.ServeFS("/", fsys)
+
+	for _, p := range []string{"/embedded.txt", "/disk.txt"} {
+		w := httptest.NewRecorder()
+		tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, p, nil))
+		etag := w.Header().Get("ETag")
+		if w.Code != 200 || etag == "" {
+			t.Errorf("%s: expected 200 with ETag, got %v %q", p, w.Code, etag)
+			continue
+		}
+
+		r := httptest.NewRequest(http.MethodGet, p, nil)
+		r.Header.Set("If-None-Match", etag)
+		w = httptest.NewRecorder()
+		tr.ServeHTTP(w, r)
+		if w.Code != http.StatusNotModified {
+			t.Errorf("%s: expected 304 for ETag, got %v", p, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/disk.txt", nil))
+	if lm := w.Header().Get("Last-Modified"); lm != mod.Format(http.TimeFormat) {
+		t.Errorf("expected Last-Modified %s, got %s", mod.Format(http.TimeFormat), lm)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/disk.txt", nil)
+	r.Header.Set("If-Modified-Since", mod.Add(time.Hour).Format(http.TimeFormat))
+	w = httptest.NewRecorder()
+	tr.ServeHTTP(w, r)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for If-Modified-Since, got %v", w.Code)
+	}
+}
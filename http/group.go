@@ -0,0 +1,120 @@
+package http
+
+import (
+	"io/fs"
+	"net/http"
+	"net/url"
+)
+
+// A group registers everything under its prefix on the TreeMux it belongs to.
+// The prefix is a cleaned pattern and can start with a host pattern.
+type group struct {
+	root   *treeMux
+	prefix string
+}
+
+func (g *group) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.root.ServeHTTP(w, r)
+}
+
+func (g *group) Handle(path string, handler http.Handler) {
+	g.root.handle("", joinPattern(g.prefix, path), handler)
+}
+
+func (g *group) HandleFunc(path string, handler http.HandlerFunc) {
+	g.root.handle("", joinPattern(g.prefix, path), handler)
+}
+
+func (g *group) HandleMethod(method, path string, handler http.Handler) {
+	g.root.handle(method, joinPattern(g.prefix, path), handler)
+}
+
+func (g *group) HandleMethodFunc(method, path string, handler http.HandlerFunc) {
+	g.root.handle(method, joinPattern(g.prefix, path), handler)
+}
+
+func (g *group) Use(middleware ...func(http.Handler) http.Handler) {
+	g.root.usePrefix(g.prefix, middleware...)
+}
+
+func (g *group) UsePrefix(prefix string, middleware ...func(http.Handler) http.Handler) {
+	g.root.usePrefix(joinPattern(g.prefix, prefix), middleware...)
+}
+
+func (g *group) SetLimits(prefix string, limits Limits) {
+	g.root.setLimits(joinPattern(g.prefix, prefix), limits)
+}
+
+func (g *group) SetNotFound(handler http.HandlerFunc) {
+	if handler == nil {
+		g.root.setNotFound(g.prefix, nil)
+		return
+	}
+	g.root.setNotFound(g.prefix, handler)
+}
+
+func (g *group) Group(prefix string) TreeMux {
+	return &group{root: g.root, prefix: joinPattern(g.prefix, prefix)}
+}
+
+// Returns a TreeMux for the host pattern with the path prefix of the group.
+func (g *group) Host(pattern string) TreeMux {
+	_, path := splitHost(g.prefix)
+	return &group{root: g.root, prefix: hostPattern(pattern) + path}
+}
+
+func (g *group) Name(name, path string) {
+	g.root.name(name, joinPattern(g.prefix, path))
+}
+
+func (g *group) URL(name string, params ...string) (string, error) {
+	return g.root.URL(name, params...)
+}
+
+func (g *group) ServeFS(prefix string, fsys fs.FS) {
+	g.root.serveFS(joinPattern(g.prefix, prefix), fsys, FSOptions{})
+}
+
+func (g *group) ServeFSWithOptions(prefix string, fsys fs.FS, opts FSOptions) {
+	g.root.serveFS(joinPattern(g.prefix, prefix), fsys, opts)
+}
+
+func (g *group) Proxy(prefix string, target *url.URL, opts ProxyOptions) {
+	g.root.proxy(joinPattern(g.prefix, prefix), target, opts)
+}
+
+func (g *group) Walk(fn func(pattern string, methods []string, h http.Handler) error) error {
+	return g.root.walk(newPathPrefix(g.prefix), fn)
+}
+
+func (g *group) Remove(path string) bool {
+	return g.root.removeRoute(joinPattern(g.prefix, path))
+}
+
+func (g *group) RemoveMethod(method, path string) bool {
+	return g.root.removeMethod(method, joinPattern(g.prefix, path))
+}
+
+func (g *group) SetMethodNotAllowed(handler http.HandlerFunc) {
+	g.root.SetMethodNotAllowed(handler)
+}
+
+func (g *group) SetPanicHandler(handler func(w http.ResponseWriter, r *http.Request, v interface{})) {
+	g.root.SetPanicHandler(handler)
+}
+
+func (g *group) SetStrict(strict bool) {
+	g.root.SetStrict(strict)
+}
+
+func (g *group) SetCleanPath(clean bool) {
+	g.root.SetCleanPath(clean)
+}
+
+func (g *group) SetTrailingSlash(policy TrailingSlash) {
+	g.root.SetTrailingSlash(policy)
+}
+
+func (g *group) Validate() error {
+	return g.root.validate(newPathPrefix(g.prefix))
+}
@@ -0,0 +1,87 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/HayoVanLoon/go-commons/pathtrie"
+)
+
+// Splits a pattern into its host pattern and path. Patterns registered through
+// Host start with their host pattern, like "api.example.com/v1/*"; all other
+// patterns start with a separator.
+func splitHost(p string) (string, string) {
+	if p == "" || p[0] == '/' {
+		return "", p
+	}
+	i := strings.IndexByte(p, '/')
+	if i < 0 {
+		i = len(p)
+	}
+	return p[:i], p[i:]
+}
+
+// Returns the key under which a route with the host pattern and path is
+// stored: the host labels as elements, an empty element and then the path.
+// Host labels never match an empty element (see checkHost), so the empty
+// element marks where the path begins. A wildcard label would match one, but
+// as request hosts never have empty labels (see requestHost), it cannot reach
+// the empty element either.
+//
+// Example:
+//   hostKey("{tenant}.example.com", "/v1/*") == "/{tenant}/example/com//v1/*"
+func hostKey(host, path string) string {
+	return "/" + strings.ReplaceAll(host, ".", "/") + "/" + path
+}
+
+// Returns the host pattern with its literal labels in lower case, as request
+// hosts are matched in lower case. Panics if the host pattern is invalid.
+func hostPattern(host string) string {
+	xs := strings.Split(host, ".")
+	for i, x := range xs {
+		if _, ok := pathtrie.ParamName(x); !ok {
+			xs[i] = strings.ToLower(x)
+		}
+	}
+	host = strings.Join(xs, ".")
+	if err := checkHost(host); err != nil {
+		panic(err)
+	}
+	return host
+}
+
+// Checks whether the host pattern can be matched: its labels cannot be empty,
+// catch-alls or named elements whose constraint accepts an empty label, nor can
+// they contain separators. Wildcard labels are allowed.
+func checkHost(host string) error {
+	for _, x := range strings.Split(host, ".") {
+		_, rest := pathtrie.CatchAllName(x)
+		invalid := rest || x == "" || strings.Contains(x, "/")
+		if c := pathtrie.ParamConstraint(x); c != "" && !invalid {
+			invalid = accepts(x, "")
+		}
+		if invalid {
+			return fmt.Errorf("invalid host pattern %q", host)
+		}
+	}
+	return nil
+}
+
+// Returns the host the request was sent to, in lower case and without port.
+// Returns an empty string if it cannot be matched against host patterns, like
+// IP version 6 addresses.
+func requestHost(r *http.Request) string {
+	h := r.Host
+	if strings.HasPrefix(h, "[") {
+		return ""
+	}
+	if i := strings.IndexByte(h, ':'); i >= 0 {
+		h = h[:i]
+	}
+	h = strings.ToLower(strings.TrimSuffix(h, "."))
+	if h == "" || h[0] == '.' || strings.Contains(h, "..") || strings.Contains(h, "/") {
+		return ""
+	}
+	return h
+}
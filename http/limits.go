@@ -0,0 +1,112 @@
+package http
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// Limits restrict how long requests may take and how large they may be. Zero
+// values are inherited from shorter prefixes; negative values lift a limit set
+// for a shorter prefix.
+type Limits struct {
+	// The time a handler has to serve a request. Its context is cancelled
+	// when the time is up and the request is answered with 503 Service
+	// Unavailable, unless a response was written already. Responses are
+	// buffered, so handlers with a timeout cannot stream.
+	Timeout time.Duration
+	// The maximum size of the request body in bytes. Requests announcing a
+	// larger body are answered with 413 Request Entity Too Large. Reading past
+	// the limit fails with an *http.MaxBytesError; if the handler then does not
+	// respond, the request is answered with 413 as well.
+	MaxBodySize int64
+}
+
+// Returns the limits, with the non-zero values of other overriding its own.
+func (l Limits) merge(other Limits) Limits {
+	if other.Timeout != 0 {
+		l.Timeout = other.Timeout
+	}
+	if other.MaxBodySize != 0 {
+		l.MaxBodySize = other.MaxBodySize
+	}
+	return l
+}
+
+// A prefixLimits holds the limits set for all routes under a path prefix.
+type prefixLimits struct {
+	prefix pathPrefix
+	limits Limits
+}
+
+// Collects the limits that apply to the pattern. Limits for longer prefixes
+// override those for shorter ones; for prefixes of equal length, later ones
+// override earlier ones.
+func collectLimits(pattern string, prefixed []prefixLimits) Limits {
+	var ps []prefixLimits
+	for _, p := range prefixed {
+		if p.prefix.covers(pattern) {
+			ps = append(ps, p)
+		}
+	}
+	sort.SliceStable(ps, func(i, j int) bool {
+		return len(ps[i].prefix) < len(ps[j].prefix)
+	})
+	var l Limits
+	for _, p := range ps {
+		l = l.merge(p.limits)
+	}
+	return l
+}
+
+// Wraps the handler so it is subject to the limits.
+func limit(h http.Handler, l Limits) http.Handler {
+	if l.MaxBodySize > 0 {
+		h = answerTooLarge(h)
+	}
+	if l.Timeout > 0 {
+		h = http.TimeoutHandler(h, l.Timeout, http.StatusText(http.StatusServiceUnavailable))
+	}
+	if l.MaxBodySize > 0 {
+		next := h
+		h = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > l.MaxBodySize {
+				http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = &limitedBody{ReadCloser: http.MaxBytesReader(w, r.Body, l.MaxBodySize)}
+			next.ServeHTTP(w, r)
+		})
+	}
+	return h
+}
+
+// A limitedBody records whether reading it failed for exceeding its limit.
+type limitedBody struct {
+	io.ReadCloser
+	exceeded bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) {
+		b.exceeded = true
+	}
+	return n, err
+}
+
+// Wraps the handler so requests whose body exceeded its limit are answered
+// with 413 Request Entity Too Large when the handler did not respond. Runs
+// within the timeout, if any, so it is not preempted by its response.
+func answerTooLarge(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := &statusWriter{ResponseWriter: w}
+		h.ServeHTTP(sw, r)
+		if b, ok := r.Body.(*limitedBody); ok && b.exceeded && sw.status == 0 {
+			http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+		}
+	})
+}
@@ -0,0 +1,110 @@
+package http
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCollectLimits(t *testing.T) {
+	prefixed := []prefixLimits{
+		{newPathPrefix("/"), Limits{Timeout: time.Second, MaxBodySize: 10}},
+		{newPathPrefix("/upload"), Limits{MaxBodySize: 1000}},
+		{newPathPrefix("/upload/big"), Limits{MaxBodySize: -1}},
+		{newPathPrefix("/slow"), Limits{Timeout: time.Minute}},
+		{newPathPrefix("/slow"), Limits{Timeout: time.Hour}},
+	}
+	cases := []struct {
+		pattern string
+		exp     Limits
+	}{
+		{"/items", Limits{time.Second, 10}},
+		{"/upload/{id}", Limits{time.Second, 1000}},
+		{"/upload/big/{id}", Limits{time.Second, -1}},
+		{"/slow", Limits{time.Hour, 10}},
+		{"api.example.com/items", Limits{time.Second, 10}},
+		{"api.example.com/upload/{id}", Limits{time.Second, 10}},
+	}
+	for i, c := range cases {
+		if act := collectLimits(c.pattern, prefixed); act != c.exp {
+			t.Errorf("%v %s: expected %v, got %v", i, c.pattern, c.exp, act)
+		}
+	}
+}
+
+func TestTreeMux_SetLimits(t *testing.T) {
+	// leaves answering oversized requests to the limit
+	read := func(w http.ResponseWriter, r *http.Request) {
+		bs, err := io.ReadAll(r.Body)
+		if err != nil {
+			return
+		}
+		_, _ = fmt.Fprintf(w, "%d", len(bs))
+	}
+	readOwn := func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	}
+	sleep := func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+			_, _ = w.Write([]byte("done"))
+		case <-r.Context().Done():
+		}
+	}
+
+	tr := NewTreeMux()
+	tr.SetLimits("/", Limits{Timeout: 50 * time.Millisecond, MaxBodySize: 4})
+	tr.HandleFunc("/small", read)
+	tr.HandleFunc("/sleep", sleep)
+	g := tr.Group("/upload")
+	g.SetLimits("/", Limits{MaxBodySize: 8})
+	g.HandleFunc("/file", read)
+	tr.SetLimits("/unlimited", Limits{Timeout: -1, MaxBodySize: -1})
+	tr.HandleFunc("/unlimited/sleep", sleep)
+	tr.HandleFunc("/unlimited/file", read)
+	tr.SetLimits("/bare", Limits{Timeout: -1})
+	tr.HandleFunc("/bare/file", read)
+	tr.HandleFunc("/bare/own", readOwn)
+
+	cases := []struct {
+		path    string
+		body    string
+		chunked bool
+		code    int
+		exp     string
+	}{
+		{"/small", "abcd", false, 200, "4"},
+		{"/small", "abcde", false, 413, ""},
+		{"/small", "abcde", true, 413, ""},
+		{"/upload/file", "abcdefgh", false, 200, "8"},
+		{"/upload/file", "abcdefghi", false, 413, ""},
+		{"/upload/file", "abcdefghi", true, 413, ""},
+		{"/bare/file", "abcd", true, 200, "4"},
+		{"/bare/file", "abcde", true, 413, ""},
+		{"/bare/own", "abcde", true, 400, ""},
+		{"/unlimited/file", strings.Repeat("x", 100), false, 200, "100"},
+		{"/sleep", "", false, 503, ""},
+		{"/unlimited/sleep", "", false, 200, "done"},
+	}
+	for i, c := range cases {
+		r := httptest.NewRequest(http.MethodPost, c.path, strings.NewReader(c.body))
+		if c.chunked {
+			r.ContentLength = -1
+		}
+		w := httptest.NewRecorder()
+		tr.ServeHTTP(w, r)
+		if w.Code != c.code {
+			t.Errorf("%v %s: expected %v, got %v", i, c.path, c.code, w.Code)
+			continue
+		}
+		if c.code == 200 && w.Body.String() != c.exp {
+			t.Errorf("%v %s: expected %s, got %s", i, c.path, c.exp, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package http
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metrics collects request metrics per route and method and exposes them in
+// the Prometheus text exposition format:
+//   http_requests_total            counter, by method, route and code
+//   http_request_duration_seconds  histogram, by method and route
+//   http_requests_in_flight        gauge, by method and route
+//
+// Routes are identified by their pattern (see RoutePattern), so the number of
+// series stays bounded. Requests matching no route have an empty route label.
+// Uncommon methods are labelled "OTHER".
+//
+// Example:
+//   m := NewMetrics()
+//   t.Use(m.Middleware)
+//   t.Handle("/metrics", m)
+type Metrics interface {
+	// Writes the metrics in the Prometheus text exposition format.
+	http.Handler
+
+	// Records the metrics of the requests passing through. Meant to be added
+	// to a TreeMux with Use.
+	Middleware(next http.Handler) http.Handler
+}
+
+// The default histogram buckets, in seconds.
+var defaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Creates new Metrics, with the given histogram bucket upper bounds in
+// seconds. Without buckets, the Prometheus default buckets are used, ranging
+// from 5 milliseconds to 10 seconds.
+func NewMetrics(buckets ...float64) Metrics {
+	if len(buckets) == 0 {
+		buckets = defaultBuckets
+	}
+	bs := append([]float64{}, buckets...)
+	sort.Float64s(bs)
+	return &metrics{
+		buckets:   bs,
+		counts:    map[seriesKey]uint64{},
+		durations: map[seriesKey]*histogram{},
+		inFlight:  map[seriesKey]int64{},
+	}
+}
+
+type seriesKey struct {
+	method string
+	route  string
+	code   int
+}
+
+type histogram struct {
+	// counts per bucket, not cumulative
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+type metrics struct {
+	buckets []float64
+
+	mu        sync.Mutex
+	counts    map[seriesKey]uint64
+	durations map[seriesKey]*histogram
+	inFlight  map[seriesKey]int64
+}
+
+// Returns the method as label value: as is for standard methods, "OTHER" for
+// the rest.
+func methodLabel(m string) string {
+	switch m {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return m
+	}
+	return "OTHER"
+}
+
+func (m *metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		k := seriesKey{method: methodLabel(r.Method), route: RoutePattern(r)}
+		m.mu.Lock()
+		m.inFlight[k] += 1
+		m.mu.Unlock()
+
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		done := false
+		defer func() {
+			if !done && sw.status == 0 {
+				sw.status = http.StatusInternalServerError
+			}
+			if sw.status == 0 {
+				sw.status = http.StatusOK
+			}
+			m.observe(k, sw.status, time.Since(start).Seconds())
+		}()
+		next.ServeHTTP(sw, r)
+		done = true
+	})
+}
+
+// Records a served request.
+func (m *metrics) observe(k seriesKey, code int, seconds float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.inFlight[k] -= 1
+	h, ok := m.durations[k]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(m.buckets))}
+		m.durations[k] = h
+	}
+	if i := sort.SearchFloat64s(m.buckets, seconds); i < len(m.buckets) {
+		h.counts[i] += 1
+	}
+	h.sum += seconds
+	h.count += 1
+	k.code = code
+	m.counts[k] += 1
+}
+
+func (m *metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.write(w)
+}
+
+// Writes the metrics in the text exposition format, with series in sorted
+// order.
+func (m *metrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, _ = fmt.Fprintln(w, "# HELP http_requests_total Total number of HTTP requests served.")
+	_, _ = fmt.Fprintln(w, "# TYPE http_requests_total counter")
+	for _, k := range sortedKeys(m.counts) {
+		_, _ = fmt.Fprintf(w, "http_requests_total{%s,code=\"%d\"} %d\n", labels(k), k.code, m.counts[k])
+	}
+
+	_, _ = fmt.Fprintln(w, "# HELP http_request_duration_seconds Duration of HTTP requests in seconds.")
+	_, _ = fmt.Fprintln(w, "# TYPE http_request_duration_seconds histogram")
+	for _, k := range sortedKeys(m.durations) {
+		h := m.durations[k]
+		var n uint64
+		for i, b := range m.buckets {
+			n += h.counts[i]
+			_, _ = fmt.Fprintf(w, "http_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n", labels(k), formatFloat(b), n)
+		}
+		_, _ = fmt.Fprintf(w, "http_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels(k), h.count)
+		_, _ = fmt.Fprintf(w, "http_request_duration_seconds_sum{%s} %s\n", labels(k), formatFloat(h.sum))
+		_, _ = fmt.Fprintf(w, "http_request_duration_seconds_count{%s} %d\n", labels(k), h.count)
+	}
+
+	_, _ = fmt.Fprintln(w, "# HELP http_requests_in_flight Number of HTTP requests being served.")
+	_, _ = fmt.Fprintln(w, "# TYPE http_requests_in_flight gauge")
+	for _, k := range sortedKeys(m.inFlight) {
+		_, _ = fmt.Fprintf(w, "http_requests_in_flight{%s} %d\n", labels(k), m.inFlight[k])
+	}
+}
+
+func sortedKeys[V any](m map[seriesKey]V) []seriesKey {
+	ks := make([]seriesKey, 0, len(m))
+	for k := range m {
+		ks = append(ks, k)
+	}
+	sort.Slice(ks, func(i, j int) bool {
+		if ks[i].route != ks[j].route {
+			return ks[i].route < ks[j].route
+		}
+		if ks[i].method != ks[j].method {
+			return ks[i].method < ks[j].method
+		}
+		return ks[i].code < ks[j].code
+	})
+	return ks
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Returns the method and route labels of the series.
+func labels(k seriesKey) string {
+	return `method="` + k.method + `",route="` + labelEscaper.Replace(k.route) + `"`
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
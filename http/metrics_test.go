@@ -0,0 +1,98 @@
+package http
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	m := NewMetrics(100)
+	tr := NewTreeMux()
+	tr.Use(m.Middleware)
+	var during string
+	tr.HandleMethodFunc(http.MethodGet, "/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		b := &bytes.Buffer{}
+		m.(*metrics).write(b)
+		during = b.String()
+	})
+	tr.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("oops")
+	})
+	tr.SetPanicHandler(func(w http.ResponseWriter, r *http.Request, v interface{}) {})
+	tr.Handle("/metrics", m)
+
+	for _, c := range []struct {
+		method string
+		path   string
+	}{
+		{http.MethodGet, "/users/1"},
+		{http.MethodGet, "/users/2"},
+		{http.MethodPost, "/users/3"},
+		{"BREW", "/users/4"},
+		{http.MethodGet, "/nope"},
+		{http.MethodGet, "/panic"},
+	} {
+		tr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(c.method, c.path, nil))
+	}
+
+	if !strings.Contains(during, `http_requests_in_flight{method="GET",route="/users/{id}"} 1`) {
+		t.Errorf("expected request in flight, got\n%s", during)
+	}
+
+	w := httptest.NewRecorder()
+	tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %s", ct)
+	}
+	var lines []string
+	for _, l := range strings.Split(w.Body.String(), "\n") {
+		if !strings.Contains(l, "_sum{") && !strings.Contains(l, `route="/metrics"`) {
+			lines = append(lines, l)
+		}
+	}
+	exp := `# HELP http_requests_total Total number of HTTP requests served.
+# TYPE http_requests_total counter
+http_requests_total{method="GET",route="",code="404"} 1
+http_requests_total{method="GET",route="/panic",code="500"} 1
+http_requests_total{method="GET",route="/users/{id}",code="200"} 2
+http_requests_total{method="OTHER",route="/users/{id}",code="405"} 1
+http_requests_total{method="POST",route="/users/{id}",code="405"} 1
+# HELP http_request_duration_seconds Duration of HTTP requests in seconds.
+# TYPE http_request_duration_seconds histogram
+http_request_duration_seconds_bucket{method="GET",route="",le="100"} 1
+http_request_duration_seconds_bucket{method="GET",route="",le="+Inf"} 1
+http_request_duration_seconds_count{method="GET",route=""} 1
+http_request_duration_seconds_bucket{method="GET",route="/panic",le="100"} 1
+http_request_duration_seconds_bucket{method="GET",route="/panic",le="+Inf"} 1
+http_request_duration_seconds_count{method="GET",route="/panic"} 1
+http_request_duration_seconds_bucket{method="GET",route="/users/{id}",le="100"} 2
+http_request_duration_seconds_bucket{method="GET",route="/users/{id}",le="+Inf"} 2
+http_request_duration_seconds_count{method="GET",route="/users/{id}"} 2
+http_request_duration_seconds_bucket{method="OTHER",route="/users/{id}",le="100"} 1
+http_request_duration_seconds_bucket{method="OTHER",route="/users/{id}",le="+Inf"} 1
+http_request_duration_seconds_count{method="OTHER",route="/users/{id}"} 1
+http_request_duration_seconds_bucket{method="POST",route="/users/{id}",le="100"} 1
+http_request_duration_seconds_bucket{method="POST",route="/users/{id}",le="+Inf"} 1
+http_request_duration_seconds_count{method="POST",route="/users/{id}"} 1
+# HELP http_requests_in_flight Number of HTTP requests being served.
+# TYPE http_requests_in_flight gauge
+http_requests_in_flight{method="GET",route=""} 0
+http_requests_in_flight{method="GET",route="/panic"} 0
+http_requests_in_flight{method="GET",route="/users/{id}"} 0
+http_requests_in_flight{method="OTHER",route="/users/{id}"} 0
+http_requests_in_flight{method="POST",route="/users/{id}"} 0
+`
+	if act := strings.Join(lines, "\n"); act != exp {
+		t.Errorf("expected\n%s\ngot\n%s", exp, act)
+	}
+}
+
+func TestLabels(t *testing.T) {
+	k := seriesKey{method: "GET", route: "/a/\"b\"\\\n"}
+	if exp, act := `method="GET",route="/a/\"b\"\\\n"`, labels(k); act != exp {
+		t.Errorf("expected %s, got %s", exp, act)
+	}
+}
@@ -0,0 +1,78 @@
+package http
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// A pathPrefix holds the elements of a path prefix.
+type pathPrefix []string
+
+// Returns the elements of the (cleaned) prefix.
+func newPathPrefix(prefix string) pathPrefix {
+	return strings.Split(strings.TrimSuffix(prefix, "/"), "/")
+}
+
+// Returns whether the pattern lies under the prefix. Elements are compared
+// literally, so a prefix "/admin" covers "/admin" and "/admin/{id}", but not
+// "/administrator" or "/*/users". The root prefix covers all patterns,
+// including those starting with a host pattern.
+func (p pathPrefix) covers(pattern string) bool {
+	if len(p) == 1 && p[0] == "" {
+		return true
+	}
+	xs := strings.Split(pattern, "/")
+	if len(xs) < len(p) {
+		return false
+	}
+	for i := range p {
+		if xs[i] != p[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// A prefixMiddleware holds the middleware registered for all routes under a
+// path prefix.
+type prefixMiddleware struct {
+	prefix     pathPrefix
+	middleware []func(http.Handler) http.Handler
+}
+
+func newPrefixMiddleware(prefix string, middleware []func(http.Handler) http.Handler) prefixMiddleware {
+	return prefixMiddleware{
+		prefix:     newPathPrefix(prefix),
+		middleware: middleware,
+	}
+}
+
+// Wraps the handler in the given middleware. The first middleware becomes the
+// outermost one.
+func chain(h http.Handler, middleware []func(http.Handler) http.Handler) http.Handler {
+	for i := len(middleware) - 1; i >= 0; i -= 1 {
+		h = middleware[i](h)
+	}
+	return h
+}
+
+// Collects the middleware that applies to the pattern: first the global
+// middleware, then the middleware of all prefixes covering it, from shortest to
+// longest prefix. Prefixes of equal length keep their registration order.
+func collectMiddleware(pattern string, global []func(http.Handler) http.Handler, prefixed []prefixMiddleware) []func(http.Handler) http.Handler {
+	var ps []prefixMiddleware
+	for _, p := range prefixed {
+		if p.prefix.covers(pattern) {
+			ps = append(ps, p)
+		}
+	}
+	sort.SliceStable(ps, func(i, j int) bool {
+		return len(ps[i].prefix) < len(ps[j].prefix)
+	})
+	mws := append([]func(http.Handler) http.Handler{}, global...)
+	for _, p := range ps {
+		mws = append(mws, p.middleware...)
+	}
+	return mws
+}
@@ -0,0 +1,58 @@
+package http
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// A TrailingSlash is a policy for requests whose path only matches a route
+// after adding or removing a trailing slash.
+type TrailingSlash int32
+
+const (
+	// The request is not routed to the other route. This is the default.
+	TrailingSlashStrict TrailingSlash = iota
+	// The request is redirected to the path of the other route.
+	TrailingSlashRedirect
+	// The request is served by the other route, as if its path matched.
+	TrailingSlashEquivalent
+)
+
+// Returns the canonical form of the path: rooted, without empty, "." or ".."
+// elements. A trailing slash is kept.
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	c := path.Clean(cleanPattern(p))
+	if strings.HasSuffix(p, "/") && c != "/" {
+		c += "/"
+	}
+	return c
+}
+
+// Returns the path with a trailing slash added or removed. Returns an empty
+// string for the root path.
+func toggleSlash(p string) string {
+	if p == "" || p == "/" {
+		return ""
+	}
+	if strings.HasSuffix(p, "/") {
+		return p[:len(p)-1]
+	}
+	return p + "/"
+}
+
+// Redirects the request to the same URL with the given path. GET and HEAD
+// requests are redirected permanently with 301 Moved Permanently, others with
+// 308 Permanent Redirect, so clients repeat the method and body.
+func redirectPath(w http.ResponseWriter, r *http.Request, p string) {
+	code := http.StatusPermanentRedirect
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		code = http.StatusMovedPermanently
+	}
+	u := *r.URL
+	u.Path, u.RawPath = p, ""
+	http.Redirect(w, r, u.String(), code)
+}
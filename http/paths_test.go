@@ -0,0 +1,28 @@
+package http
+
+import (
+	"testing"
+)
+
+func TestCleanPath(t *testing.T) {
+	cases := []struct {
+		input string
+		exp   string
+	}{
+		{"", "/"},
+		{"/", "/"},
+		{"a/b", "/a/b"},
+		{"/a/b/", "/a/b/"},
+		{"//a///b", "/a/b"},
+		{"/a/./b/", "/a/b/"},
+		{"/a/../b", "/b"},
+		{"/../a", "/a"},
+		{"/a/..", "/"},
+		{"/a/../", "/"},
+	}
+	for i, c := range cases {
+		if act := cleanPath(c.input); act != c.exp {
+			t.Errorf("%v: [%s] expected %s, got %s", i, c.input, c.exp, act)
+		}
+	}
+}
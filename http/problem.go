@@ -0,0 +1,131 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/HayoVanLoon/go-commons/logjson"
+)
+
+// A Problem is an error response as described by RFC 7807, written as
+// application/problem+json. It is also an error, so handlers can return it
+// (wrapped or not) to have it written.
+type Problem struct {
+	// A URI identifying the problem type. Defaults to "about:blank".
+	Type   string `json:"type,omitempty"`
+	Title  string `json:"title,omitempty"`
+	Status int    `json:"status,omitempty"`
+	Detail string `json:"detail,omitempty"`
+	// A URI identifying this occurrence, the request path by default.
+	Instance   string      `json:"instance,omitempty"`
+	Violations []Violation `json:"violations,omitempty"`
+}
+
+// Creates a problem with the status, the standard text for the status as
+// title and the given detail.
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{Title: http.StatusText(status), Status: status, Detail: detail}
+}
+
+func (p *Problem) Error() string {
+	if p.Detail == "" {
+		return p.Title
+	}
+	return p.Title + ": " + p.Detail
+}
+
+// Common problems, to be returned or wrapped by handlers.
+var (
+	ErrBadRequest = NewProblem(http.StatusBadRequest, "")
+	ErrForbidden  = NewProblem(http.StatusForbidden, "")
+	ErrNotFound   = NewProblem(http.StatusNotFound, "")
+	ErrConflict   = NewProblem(http.StatusConflict, "")
+)
+
+// A Violation describes why the value of a field is invalid.
+type Violation struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Violations is an error listing invalid fields. It is written as a problem
+// with status 422 Unprocessable Entity.
+type Violations []Violation
+
+func (vs Violations) Error() string {
+	xs := make([]string, len(vs))
+	for i, v := range vs {
+		xs[i] = v.Field + ": " + v.Message
+	}
+	return "invalid fields: " + strings.Join(xs, "; ")
+}
+
+// Returns the problem for the error:
+//   *Problem                   the problem itself, also when wrapped
+//   Violations                 422 Unprocessable Entity
+//   *http.MaxBytesError        413 Request Entity Too Large
+//   context.DeadlineExceeded   503 Service Unavailable
+//   interface{StatusCode() int} that status, with the error as detail
+// Other errors yield a 500 Internal Server Error without detail.
+func ProblemFor(err error) *Problem {
+	var p *Problem
+	var vs Violations
+	var mbe *http.MaxBytesError
+	var sc interface{ StatusCode() int }
+	switch {
+	case errors.As(err, &p):
+		c := *p
+		if c.Detail == "" && err != p {
+			c.Detail = err.Error()
+		}
+		return &c
+	case errors.As(err, &vs):
+		c := NewProblem(http.StatusUnprocessableEntity, "")
+		c.Violations = vs
+		return c
+	case errors.As(err, &mbe):
+		return NewProblem(http.StatusRequestEntityTooLarge, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return NewProblem(http.StatusServiceUnavailable, "")
+	case errors.As(err, &sc):
+		return NewProblem(sc.StatusCode(), err.Error())
+	}
+	return NewProblem(http.StatusInternalServerError, "")
+}
+
+// Writes the problem for the error (see ProblemFor). Errors without a status
+// of their own are logged, as their details are not sent to the client.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	p := ProblemFor(err)
+	if p.Status == http.StatusInternalServerError {
+		logjson.Error("%s %s: %v", r.Method, r.URL.Path, err)
+	}
+	if p.Instance == "" {
+		p.Instance = r.URL.Path
+	}
+	WriteProblem(w, p)
+}
+
+// Writes the problem as application/problem+json.
+func WriteProblem(w http.ResponseWriter, p *Problem) {
+	if p.Status == 0 {
+		p.Status = http.StatusInternalServerError
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// Writes the value as JSON with the given status.
+func WriteJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
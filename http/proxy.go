@@ -0,0 +1,136 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/HayoVanLoon/go-commons/logjson"
+)
+
+// ProxyOptions configure how requests are forwarded to an upstream.
+type ProxyOptions struct {
+	// Whether the route prefix is removed from the path before forwarding.
+	// Only the remainder matched by the catch-all of the route is appended
+	// to the path of the upstream.
+	StripPrefix bool
+	// If set, replaces the route prefix in the forwarded path, like
+	// StripPrefix followed by adding this prefix.
+	RewritePrefix string
+	// More upstreams to forward to. Requests are distributed over all
+	// upstreams in turn.
+	Upstreams []*url.URL
+	// The time the upstream has to respond. Responds with 504 Gateway Timeout
+	// when exceeded. No timeout if zero.
+	Timeout time.Duration
+	// The time an upstream is skipped after a request to it failed. Defaults
+	// to 10 seconds.
+	FailTimeout time.Duration
+	// Whether the X-Forwarded-For header of incoming requests is trusted and
+	// extended, rather than replaced.
+	TrustForwarded bool
+	// The transport used for forwarding. Defaults to
+	// http.DefaultTransport.
+	Transport http.RoundTripper
+}
+
+// Returns a handler forwarding requests to the target and the upstreams in the
+// options, in turn. It is meant for a route ending in a catch-all, whose
+// remainder is the path to forward when stripping or rewriting the prefix.
+// X-Forwarded-For, X-Forwarded-Host and X-Forwarded-Proto headers are set.
+//
+// Upstreams are marked unhealthy when a request to them fails and are then
+// skipped for the fail timeout, unless all of them are unhealthy. Failed
+// requests are answered with 502 Bad Gateway, or with 504 Gateway Timeout when
+// the timeout passed.
+func NewProxy(target *url.URL, opts ProxyOptions) http.Handler {
+	if opts.FailTimeout <= 0 {
+		opts.FailTimeout = 10 * time.Second
+	}
+	p := &proxy{opts: opts}
+	for _, u := range append([]*url.URL{target}, opts.Upstreams...) {
+		p.upstreams = append(p.upstreams, &upstream{url: u})
+	}
+	p.rp = &httputil.ReverseProxy{
+		Rewrite:      p.rewrite,
+		Transport:    opts.Transport,
+		ErrorHandler: p.error,
+	}
+	return p
+}
+
+type proxy struct {
+	opts      ProxyOptions
+	upstreams []*upstream
+	next      atomic.Uint64
+	rp        *httputil.ReverseProxy
+}
+
+type upstream struct {
+	url *url.URL
+	// the time until which the upstream is skipped, in Unix nanoseconds
+	downUntil atomic.Int64
+}
+
+func (u *upstream) healthy(now time.Time) bool {
+	return now.UnixNano() >= u.downUntil.Load()
+}
+
+type upstreamKey struct{}
+
+// Returns the next healthy upstream in turn, or simply the next one if none
+// are healthy.
+func (p *proxy) pick() *upstream {
+	n := uint64(len(p.upstreams))
+	i := p.next.Add(1) - 1
+	now := time.Now()
+	for j := uint64(0); j < n; j += 1 {
+		if u := p.upstreams[(i+j)%n]; u.healthy(now) {
+			return u
+		}
+	}
+	return p.upstreams[i%n]
+}
+
+func (p *proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := context.WithValue(r.Context(), upstreamKey{}, p.pick())
+	if p.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.opts.Timeout)
+		defer cancel()
+	}
+	p.rp.ServeHTTP(w, r.WithContext(ctx))
+}
+
+func (p *proxy) rewrite(pr *httputil.ProxyRequest) {
+	if p.opts.StripPrefix || p.opts.RewritePrefix != "" {
+		pr.Out.URL.Path = strings.TrimSuffix(p.opts.RewritePrefix, "/") + "/" + PathRemainder(pr.In)
+		pr.Out.URL.RawPath = ""
+	}
+	pr.SetURL(pr.In.Context().Value(upstreamKey{}).(*upstream).url)
+	if p.opts.TrustForwarded {
+		pr.Out.Header["X-Forwarded-For"] = pr.In.Header["X-Forwarded-For"]
+	}
+	pr.SetXForwarded()
+}
+
+func (p *proxy) error(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, context.Canceled) {
+		// the client went away, which says nothing about the upstream
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	u := r.Context().Value(upstreamKey{}).(*upstream)
+	u.downUntil.Store(time.Now().Add(p.opts.FailTimeout).UnixNano())
+	logjson.Warn("proxy to %s failed: %v", u.url, err)
+	if errors.Is(err, context.DeadlineExceeded) {
+		http.Error(w, http.StatusText(http.StatusGatewayTimeout), http.StatusGatewayTimeout)
+		return
+	}
+	http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+}
@@ -0,0 +1,102 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func newUpstream(t *testing.T, name string) (*httptest.Server, *url.URL) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		_, _ = fmt.Fprintf(w, "%s %s?%s %s %s", name, r.URL.Path, r.URL.RawQuery, r.Header.Get("X-Forwarded-For"), r.Header.Get("X-Forwarded-Host"))
+	}))
+	t.Cleanup(s.Close)
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, u
+}
+
+func TestTreeMux_Proxy(t *testing.T) {
+	_, a := newUpstream(t, "a")
+	_, b := newUpstream(t, "b")
+	base := *a
+	base.Path = "/base"
+
+	tr := NewTreeMux()
+	tr.Proxy("/keep", &base, ProxyOptions{})
+	tr.Proxy("/strip", a, ProxyOptions{StripPrefix: true})
+	tr.Group("/api").Proxy("/v1", a, ProxyOptions{RewritePrefix: "/v2"})
+	tr.Proxy("/rr", a, ProxyOptions{StripPrefix: true, Upstreams: []*url.URL{b}})
+	tr.Proxy("/timeout", a, ProxyOptions{StripPrefix: true, Timeout: 50 * time.Millisecond})
+
+	cases := []struct {
+		path   string
+		header string
+		code   int
+		body   string
+	}{
+		{"/keep/x?q=1", "", 200, "a /base/keep/x?q=1 192.0.2.1 example.com"},
+		{"/strip/x/y?q=1", "", 200, "a /x/y?q=1 192.0.2.1 example.com"},
+		{"/strip", "", 200, "a /? 192.0.2.1 example.com"},
+		{"/strip/x", "10.0.0.1", 200, "a /x? 192.0.2.1 example.com"},
+		{"/api/v1/items", "", 200, "a /v2/items? 192.0.2.1 example.com"},
+		{"/rr/1", "", 200, "a /1? 192.0.2.1 example.com"},
+		{"/rr/2", "", 200, "b /2? 192.0.2.1 example.com"},
+		{"/rr/3", "", 200, "a /3? 192.0.2.1 example.com"},
+		{"/timeout/slow", "", 504, ""},
+	}
+	for i, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, c.path, nil)
+		if c.header != "" {
+			r.Header.Set("X-Forwarded-For", c.header)
+		}
+		w := httptest.NewRecorder()
+		tr.ServeHTTP(w, r)
+		if w.Code != c.code {
+			t.Errorf("%v %s: expected %v, got %v", i, c.path, c.code, w.Code)
+			continue
+		}
+		if c.code == 200 && w.Body.String() != c.body {
+			t.Errorf("%v %s: expected %q, got %q", i, c.path, c.body, w.Body.String())
+		}
+	}
+}
+
+func TestNewProxy_TrustForwarded(t *testing.T) {
+	_, a := newUpstream(t, "a")
+	tr := NewTreeMux()
+	tr.Proxy("/", a, ProxyOptions{TrustForwarded: true})
+
+	r := httptest.NewRequest(http.MethodGet, "/x", nil)
+	r.Header.Set("X-Forwarded-For", "10.0.0.1")
+	w := httptest.NewRecorder()
+	tr.ServeHTTP(w, r)
+	if exp := "a /x? 10.0.0.1, 192.0.2.1 example.com"; w.Body.String() != exp {
+		t.Errorf("expected %q, got %q", exp, w.Body.String())
+	}
+}
+
+func TestNewProxy_PassiveHealth(t *testing.T) {
+	down, a := newUpstream(t, "a")
+	down.Close()
+	_, b := newUpstream(t, "b")
+
+	p := NewProxy(a, ProxyOptions{Upstreams: []*url.URL{b}, FailTimeout: time.Minute})
+	var codes []int
+	for i := 0; i < 4; i += 1 {
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/x", nil))
+		codes = append(codes, w.Code)
+	}
+	if exp := fmt.Sprint([]int{502, 200, 200, 200}); fmt.Sprint(codes) != exp {
+		t.Errorf("expected %s, got %v", exp, codes)
+	}
+}
@@ -0,0 +1,204 @@
+package http
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimitOptions configure a rate limiter.
+type RateLimitOptions struct {
+	// The number of requests per second allowed in the long run. Must be
+	// positive.
+	Rate float64
+	// The number of requests allowed in quick succession. Defaults to the
+	// rate, rounded up.
+	Burst int
+	// Returns the key requests are limited by; each key has its own bucket.
+	// Requests for which it returns an empty string are not limited. Defaults
+	// to ClientIPKey(0).
+	Key func(r *http.Request) string
+	// The time after which the bucket of a key that has not been used is
+	// evicted. It is at least the time an empty bucket takes to fill up and
+	// at least a minute.
+	IdleTimeout time.Duration
+}
+
+// Returns a key function yielding the IP address of the client. With zero
+// trusted proxies, this is the address of the connection. Otherwise it is
+// taken from the X-Forwarded-For header: the address the last of the trusted
+// proxies received the request from.
+//
+// Example:
+// Behind a single load balancer, a request from 10.0.0.1 with the header
+//   X-Forwarded-For: 1.2.3.4, 10.0.0.1
+// would have client IP 10.0.0.1 for ClientIPKey(1); the first address in the
+// header can be forged by the client.
+func ClientIPKey(trustedProxies int) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		ip := r.RemoteAddr
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+		if trustedProxies <= 0 {
+			return ip
+		}
+		var xs []string
+		for _, h := range r.Header.Values("X-Forwarded-For") {
+			for _, x := range strings.Split(h, ",") {
+				xs = append(xs, strings.TrimSpace(x))
+			}
+		}
+		xs = append(xs, ip)
+		i := len(xs) - 1 - trustedProxies
+		if i < 0 {
+			i = 0
+		}
+		return xs[i]
+	}
+}
+
+// Returns a key function yielding the value of the header, like an API key.
+func HeaderKey(name string) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// A key function yielding the method and pattern of the matched route, so all
+// clients share a bucket per route.
+func RouteKey(r *http.Request) string {
+	if p := RoutePattern(r); p != "" {
+		return r.Method + " " + p
+	}
+	return ""
+}
+
+// Returns middleware limiting the rate of requests with a token bucket per
+// key. Every request takes a token; tokens are added at the rate, up to the
+// burst size. Requests finding the bucket empty are answered with 429 Too
+// Many Requests and a Retry-After header. All responses carry RateLimit-Limit,
+// RateLimit-Remaining and RateLimit-Reset headers, the latter holding the
+// seconds until the bucket is full again.
+//
+// The buckets are shared by all routes the middleware is added to, so adding
+// it to a group limits requests to the group as a whole.
+//
+// Example:
+//   t.Group("/api").Use(RateLimit(RateLimitOptions{Rate: 10, Burst: 20}))
+func RateLimit(opts RateLimitOptions) func(http.Handler) http.Handler {
+	l := newRateLimiter(opts, time.Now)
+	return l.middleware
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type rateLimiter struct {
+	rate  float64
+	burst float64
+	key   func(r *http.Request) string
+	idle  time.Duration
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func newRateLimiter(opts RateLimitOptions, now func() time.Time) *rateLimiter {
+	if opts.Rate <= 0 {
+		panic("rate limit must be positive")
+	}
+	if opts.Burst <= 0 {
+		opts.Burst = int(math.Ceil(opts.Rate))
+	}
+	if opts.Key == nil {
+		opts.Key = ClientIPKey(0)
+	}
+	fill := time.Duration(float64(opts.Burst) / opts.Rate * float64(time.Second))
+	if opts.IdleTimeout < fill {
+		opts.IdleTimeout = fill
+	}
+	if opts.IdleTimeout < time.Minute {
+		opts.IdleTimeout = time.Minute
+	}
+	return &rateLimiter{
+		rate:      opts.Rate,
+		burst:     float64(opts.Burst),
+		key:       opts.Key,
+		idle:      opts.IdleTimeout,
+		now:       now,
+		buckets:   map[string]*bucket{},
+		lastSweep: now(),
+	}
+}
+
+// Takes a token from the bucket for the key. Returns whether there was one,
+// the tokens left and the time until the bucket is full, or, if there was no
+// token, until there is one.
+func (l *rateLimiter) take(key string) (bool, int, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastSweep) >= l.idle {
+		l.sweep(now)
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst}
+		l.buckets[key] = b
+	} else {
+		b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false, 0, l.seconds(1 - b.tokens)
+	}
+	b.tokens -= 1
+	return true, int(b.tokens), l.seconds(l.burst - b.tokens)
+}
+
+// Returns the time it takes to add the number of tokens.
+func (l *rateLimiter) seconds(tokens float64) time.Duration {
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+// Evicts the buckets that have not been used for the idle timeout. Expects
+// the lock to be held.
+func (l *rateLimiter) sweep(now time.Time) {
+	for k, b := range l.buckets {
+		if now.Sub(b.last) >= l.idle {
+			delete(l.buckets, k)
+		}
+	}
+	l.lastSweep = now
+}
+
+func (l *rateLimiter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := l.key(r)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ok, remaining, wait := l.take(key)
+		secs := strconv.Itoa(int(math.Ceil(wait.Seconds())))
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(int(l.burst)))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(remaining))
+		w.Header().Set("RateLimit-Reset", secs)
+		if !ok {
+			w.Header().Set("Retry-After", secs)
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
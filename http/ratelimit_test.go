@@ -0,0 +1,122 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func TestRateLimiter_Take(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := newRateLimiter(RateLimitOptions{Rate: 2, Burst: 3}, clock.now)
+
+	cases := []struct {
+		advance   time.Duration
+		key       string
+		ok        bool
+		remaining int
+		wait      time.Duration
+	}{
+		{0, "a", true, 2, 500 * time.Millisecond},
+		{0, "a", true, 1, time.Second},
+		{0, "a", true, 0, 1500 * time.Millisecond},
+		{0, "a", false, 0, 500 * time.Millisecond},
+		{0, "b", true, 2, 500 * time.Millisecond},
+		{250 * time.Millisecond, "a", false, 0, 250 * time.Millisecond},
+		{250 * time.Millisecond, "a", true, 0, 1500 * time.Millisecond},
+		{time.Hour, "a", true, 2, 500 * time.Millisecond},
+	}
+	for i, c := range cases {
+		clock.t = clock.t.Add(c.advance)
+		ok, remaining, wait := l.take(c.key)
+		if ok != c.ok || remaining != c.remaining || wait != c.wait {
+			t.Errorf("%v: expected (%v, %v, %v), got (%v, %v, %v)", i, c.ok, c.remaining, c.wait, ok, remaining, wait)
+		}
+	}
+}
+
+func TestRateLimiter_Sweep(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := newRateLimiter(RateLimitOptions{Rate: 1, IdleTimeout: time.Minute}, clock.now)
+
+	l.take("a")
+	clock.t = clock.t.Add(30 * time.Second)
+	l.take("b")
+	clock.t = clock.t.Add(30 * time.Second)
+	l.take("c")
+	if _, ok := l.buckets["a"]; ok || len(l.buckets) != 2 {
+		t.Errorf("expected a to be evicted, got %v", l.buckets)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	tr := NewTreeMux()
+	tr.Group("/api").Use(RateLimit(RateLimitOptions{Rate: 0.5, Burst: 2, Key: HeaderKey("X-Api-Key")}))
+	tr.HandleFunc("/api/items", func(w http.ResponseWriter, r *http.Request) {})
+	tr.HandleFunc("/api/users", func(w http.ResponseWriter, r *http.Request) {})
+
+	cases := []struct {
+		path      string
+		key       string
+		code      int
+		remaining string
+		reset     string
+		retry     string
+	}{
+		{"/api/items", "k1", 200, "1", "2", ""},
+		{"/api/users", "k1", 200, "0", "4", ""},
+		{"/api/items", "k1", 429, "0", "2", "2"},
+		{"/api/items", "k2", 200, "1", "2", ""},
+		{"/api/items", "", 200, "", "", ""},
+	}
+	for i, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, c.path, nil)
+		if c.key != "" {
+			r.Header.Set("X-Api-Key", c.key)
+		}
+		w := httptest.NewRecorder()
+		tr.ServeHTTP(w, r)
+		h := w.Header()
+		if w.Code != c.code || h.Get("RateLimit-Remaining") != c.remaining || h.Get("RateLimit-Reset") != c.reset || h.Get("Retry-After") != c.retry {
+			t.Errorf("%v %s %s: expected %v %s %s %s, got %v %s %s %s", i, c.path, c.key, c.code, c.remaining, c.reset, c.retry,
+				w.Code, h.Get("RateLimit-Remaining"), h.Get("RateLimit-Reset"), h.Get("Retry-After"))
+		}
+		if c.key != "" && h.Get("RateLimit-Limit") != "2" {
+			t.Errorf("%v: expected limit 2, got %s", i, h.Get("RateLimit-Limit"))
+		}
+	}
+}
+
+func TestClientIPKey(t *testing.T) {
+	cases := []struct {
+		trusted int
+		xff     []string
+		exp     string
+	}{
+		{0, nil, "192.0.2.1"},
+		{0, []string{"1.2.3.4"}, "192.0.2.1"},
+		{1, nil, "192.0.2.1"},
+		{1, []string{"1.2.3.4"}, "1.2.3.4"},
+		{1, []string{"6.6.6.6, 1.2.3.4"}, "1.2.3.4"},
+		{2, []string{"6.6.6.6, 1.2.3.4", "10.0.0.1"}, "1.2.3.4"},
+		{5, []string{"1.2.3.4"}, "1.2.3.4"},
+	}
+	for i, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		for _, x := range c.xff {
+			r.Header.Add("X-Forwarded-For", x)
+		}
+		if act := ClientIPKey(c.trusted)(r); act != c.exp {
+			t.Errorf("%v: expected %s, got %s", i, c.exp, act)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package http
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// A route holds the handlers registered for a single path, by HTTP method.
+// Handlers registered without a method are stored under the empty string and
+// accept any method.
+type route struct {
+	pattern  string
+	handlers map[string]http.Handler
+
+	// the route wrapped in its middleware
+	chain http.Handler
+}
+
+func newRoute(pattern string) *route {
+	return &route{pattern: pattern, handlers: map[string]http.Handler{}}
+}
+
+// Returns a copy of the route with the handler for the method replaced, or
+// removed if it is nil. The route itself may be nil. Once a route is in use, its
+// handlers are never modified, so copies can share them safely.
+func (rt *route) with(pattern, method string, h http.Handler) *route {
+	c := newRoute(pattern)
+	if rt != nil {
+		for m := range rt.handlers {
+			c.handlers[m] = rt.handlers[m]
+		}
+	}
+	if h == nil {
+		delete(c.handlers, method)
+	} else {
+		c.handlers[method] = h
+	}
+	return c
+}
+
+// Returns the handler for the given method. A HEAD request falls back to the
+// GET handler, any other request to the method-less handler.
+func (rt *route) handler(method string) (http.Handler, bool) {
+	if h, ok := rt.handlers[method]; ok {
+		return h, true
+	}
+	if method == http.MethodHead {
+		if h, ok := rt.handlers[http.MethodGet]; ok {
+			return h, true
+		}
+	}
+	h, ok := rt.handlers[""]
+	return h, ok
+}
+
+// Returns the methods the route has handlers for, in sorted order. The
+// method-less handler is listed as "*".
+func (rt *route) methods() []string {
+	ms := make([]string, 0, len(rt.handlers))
+	for m := range rt.handlers {
+		if m == "" {
+			m = "*"
+		}
+		ms = append(ms, m)
+	}
+	sort.Strings(ms)
+	return ms
+}
+
+// Returns all methods the route has a handler for, including the implicit HEAD
+// and OPTIONS, in sorted order.
+func (rt *route) allowed() []string {
+	ms := make([]string, 0, len(rt.handlers)+2)
+	seen := map[string]bool{"": true}
+	add := func(m string) {
+		if !seen[m] {
+			seen[m] = true
+			ms = append(ms, m)
+		}
+	}
+	for m := range rt.handlers {
+		add(m)
+		if m == http.MethodGet {
+			add(http.MethodHead)
+		}
+	}
+	add(http.MethodOptions)
+	sort.Strings(ms)
+	return ms
+}
+
+// Serves the request with the handler for its method. Answers OPTIONS requests
+// when no handler has been registered for them and passes requests with an
+// unsupported method on to the method-not-allowed handler, after setting the
+// Allow header.
+func (rt *route) serve(w http.ResponseWriter, r *http.Request, methodNotAllowed http.Handler) {
+	if h, ok := rt.handler(r.Method); ok {
+		h.ServeHTTP(w, r)
+		return
+	}
+	ms := rt.allowed()
+	w.Header().Set("Allow", strings.Join(ms, ", "))
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	methodNotAllowed.ServeHTTP(w, withOutcome(r, Outcome{Pattern: rt.pattern, Allowed: ms}))
+}
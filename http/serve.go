@@ -0,0 +1,144 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/HayoVanLoon/go-commons/logjson"
+)
+
+// Options for Serve. Zero values select the defaults, negative durations
+// disable a timeout.
+type ServeOptions struct {
+	// The address to listen on. Defaults to ":$PORT", or ":8080" when PORT
+	// is not set.
+	Addr string
+	// Defaults to 10 seconds.
+	ReadHeaderTimeout time.Duration
+	// Defaults to no timeout; use Limits for timeouts per route.
+	ReadTimeout time.Duration
+	// Defaults to no timeout; use Limits for timeouts per route.
+	WriteTimeout time.Duration
+	// Defaults to 2 minutes.
+	IdleTimeout time.Duration
+	// The time in-flight requests get to finish after a shutdown signal.
+	// Defaults to 8 seconds, which leaves the default HookTimeout within the
+	// 10 seconds Cloud Run allows between SIGTERM and SIGKILL.
+	GracePeriod time.Duration
+	// The time the shutdown hooks get together, once the server has stopped.
+	// Defaults to 2 seconds.
+	HookTimeout time.Duration
+	// Called in reverse order after the server has stopped, with a context
+	// that expires after the HookTimeout. The hooks get this time even when
+	// the grace period ran out. Requests still in flight then have had their
+	// connections closed, but their handlers may still be running.
+	OnShutdown []func(ctx context.Context) error
+}
+
+const (
+	defaultPort              = "8080"
+	defaultReadHeaderTimeout = 10 * time.Second
+	defaultIdleTimeout       = 2 * time.Minute
+	defaultGracePeriod       = 8 * time.Second
+	defaultHookTimeout       = 2 * time.Second
+)
+
+// Serves the handler until the context is done or the process receives a
+// SIGTERM or SIGINT. It then stops accepting connections, waits for
+// in-flight requests, runs the shutdown hooks and flushes the logs.
+//
+// Returns nil after a clean shutdown. Otherwise, returns the error that
+// stopped the server or the errors of the shutdown.
+func Serve(ctx context.Context, handler http.Handler, opts ServeOptions) error {
+	ln, err := net.Listen("tcp", serveAddr(opts.Addr))
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	return serve(ctx, ln, handler, opts)
+}
+
+func serve(ctx context.Context, ln net.Listener, handler http.Handler, opts ServeOptions) error {
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: orDefault(opts.ReadHeaderTimeout, defaultReadHeaderTimeout),
+		ReadTimeout:       orDefault(opts.ReadTimeout, 0),
+		WriteTimeout:      orDefault(opts.WriteTimeout, 0),
+		IdleTimeout:       orDefault(opts.IdleTimeout, defaultIdleTimeout),
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.Serve(ln)
+	}()
+	logjson.Info("listening on %s", ln.Addr())
+
+	select {
+	case err := <-errs:
+		return errors.Join(err, shutdown(opts), logjson.Flush())
+	case <-ctx.Done():
+	}
+
+	logjson.Info("shutting down")
+	sctx, cancel := withTimeout(orDefault(opts.GracePeriod, defaultGracePeriod))
+	defer cancel()
+	err := srv.Shutdown(sctx)
+	if err != nil {
+		_ = srv.Close()
+	}
+	err = errors.Join(err, shutdown(opts))
+	if err != nil {
+		logjson.Error("shutdown: %v", err)
+	}
+	return errors.Join(err, logjson.Flush())
+}
+
+// Runs the hooks for a stopped server, giving them their own time.
+func shutdown(opts ServeOptions) error {
+	ctx, cancel := withTimeout(orDefault(opts.HookTimeout, defaultHookTimeout))
+	defer cancel()
+	return runHooks(ctx, opts.OnShutdown)
+}
+
+// Returns a context that expires after the duration, or never for zero.
+func withTimeout(d time.Duration) (context.Context, context.CancelFunc) {
+	if d == 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), d)
+}
+
+func runHooks(ctx context.Context, hooks []func(context.Context) error) error {
+	var errs []error
+	for i := len(hooks) - 1; i >= 0; i -= 1 {
+		errs = append(errs, hooks[i](ctx))
+	}
+	return errors.Join(errs...)
+}
+
+func serveAddr(addr string) string {
+	if addr != "" {
+		return addr
+	}
+	if port := os.Getenv("PORT"); port != "" {
+		return ":" + port
+	}
+	return ":" + defaultPort
+}
+
+func orDefault(d, def time.Duration) time.Duration {
+	switch {
+	case d < 0:
+		return 0
+	case d == 0:
+		return def
+	}
+	return d
+}
@@ -0,0 +1,120 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServeAddr(t *testing.T) {
+	t.Setenv("PORT", "")
+	if act := serveAddr(""); act != ":8080" {
+		t.Errorf("expected :8080, got %s", act)
+	}
+	t.Setenv("PORT", "9000")
+	if act := serveAddr(""); act != ":9000" {
+		t.Errorf("expected :9000, got %s", act)
+	}
+	if act := serveAddr("localhost:1234"); act != "localhost:1234" {
+		t.Errorf("expected localhost:1234, got %s", act)
+	}
+}
+
+func TestServe_Shutdown(t *testing.T) {
+	slow := func(d time.Duration) (http.Handler, chan struct{}) {
+		started := make(chan struct{})
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			time.Sleep(d)
+			_, _ = w.Write([]byte("done"))
+		}), started
+	}
+	cases := []struct {
+		delay   time.Duration
+		grace   time.Duration
+		hookErr error
+		expBody string
+		expErr  bool
+	}{
+		{100 * time.Millisecond, time.Second, nil, "done", false},
+		{100 * time.Millisecond, time.Second, errors.New("oops"), "done", true},
+		{time.Second, 100 * time.Millisecond, nil, "", true},
+	}
+	for i, c := range cases {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		h, started := slow(c.delay)
+		var calls []string
+		var hookErr error
+		opts := ServeOptions{
+			GracePeriod: c.grace,
+			HookTimeout: time.Second,
+			OnShutdown: []func(context.Context) error{
+				func(ctx context.Context) error {
+					calls = append(calls, "first")
+					hookErr = ctx.Err()
+					return nil
+				},
+				func(context.Context) error {
+					calls = append(calls, "second")
+					return c.hookErr
+				},
+			},
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() {
+			done <- serve(ctx, ln, h, opts)
+		}()
+
+		bodies := make(chan string)
+		go func() {
+			resp, err := http.Get("http://" + ln.Addr().String())
+			if err != nil {
+				bodies <- ""
+				return
+			}
+			bs, _ := io.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+			bodies <- string(bs)
+		}()
+		<-started
+		cancel()
+
+		if body := <-bodies; body != c.expBody {
+			t.Errorf("%v: expected body %q, got %q", i, c.expBody, body)
+		}
+		if err := <-done; (err != nil) != c.expErr {
+			t.Errorf("%v: expected error %v, got %v", i, c.expErr, err)
+		}
+		if act := strings.Join(calls, ","); act != "second,first" {
+			t.Errorf("%v: expected hooks to run in reverse order, got %s", i, act)
+		}
+		// hooks get their own time, even when the grace period ran out
+		if hookErr != nil {
+			t.Errorf("%v: expected hooks to get an unexpired context, got %v", i, hookErr)
+		}
+		if _, err := net.Dial("tcp", ln.Addr().String()); err == nil {
+			t.Errorf("%v: expected listener to be closed", i)
+		}
+	}
+}
+
+func TestServe_ListenError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	err = Serve(context.Background(), http.NotFoundHandler(), ServeOptions{Addr: ln.Addr().String()})
+	if err == nil {
+		t.Errorf("expected error")
+	}
+}
@@ -1,6 +1,20 @@
 package http
 
-import "net/http"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+
+	"github.com/HayoVanLoon/go-commons/pathtrie"
+)
 
 // A TreeMux is a request multiplexer that uses a tree structure to route
 // requests.
@@ -14,6 +28,93 @@ import "net/http"
 //   "/foo/bar/bla"
 //   "/foo/moo/bla"
 //
+// Named elements ("{name}") match like wildcards, but only non-empty elements,
+// and store the value they matched in the request context. Handlers can
+// retrieve it using PathParam.
+//
+// Example:
+// After the following mapping:
+//   t.Handle("/users/{id}/orders/{orderId}", fn)
+// A request for "/users/42/orders/7" would be handled by `fn`, with
+//   PathParam(r, "id")      == "42"
+//   PathParam(r, "orderId") == "7"
+//
+// A catch-all ("**" or "{name...}") as the last element matches zero or more
+// remaining elements. The remainder is available through PathRemainder, and for
+// the named variant also through PathParam.
+//
+// Example:
+// After the following mapping:
+//   t.Handle("/static/{file...}", fn)
+// The following requests would each be handled by `fn`:
+//   "/static"           (PathRemainder(r) == "")
+//   "/static/"          (PathRemainder(r) == "")
+//   "/static/css/a.css" (PathRemainder(r) == "css/a.css")
+//
+// A named element can have a constraint ("{name:constraint}"). It then only
+// matches elements that satisfy the constraint; otherwise routing falls through
+// to the next candidate route. The constraint "int" accepts base 10 integers,
+// "date" accepts dates like 2006-01-02 and anything else is a regular
+// expression that must match the element as a whole. PathParamAs returns the
+// converted value.
+//
+// Example:
+// After the following mapping:
+//   t.Handle("/orders/{id:int}", fn)
+//   t.Handle("/files/{name:[a-z0-9_-]+}", fn2)
+// A request for "/orders/42" would be handled by `fn`, with
+//   PathParamAs[int64](r, "id") == 42, true
+// A request for "/orders/abc" or "/files/A.txt" would not be found.
+//
+// When more than one route matches a request, the most specific one wins,
+// regardless of the order in which they were registered. A literal element is
+// preferred over a named element with a constraint, that over a named element
+// without one, a named element over a wildcard and a wildcard over a
+// catch-all. Between equally specific named elements, the name sorting first
+// wins.
+//
+// Routes registered through Host only match requests for hosts matching its
+// host pattern, like "api.example.com". Host patterns are matched label by
+// label against the request host (in lower case, without port), so labels can
+// be wildcards or named elements. Their patterns start with the host pattern,
+// like "api.example.com/v1/*". Routes without host pattern act as fallback: they
+// serve requests that no route with a matching host pattern does. The same
+// goes for not-found handlers set through Host.
+//
+// Example:
+// After the following mapping:
+//   t.Host("{tenant}.example.com").Handle("/v1/*", fn)
+//   t.Handle("/v1/*", fn2)
+// A request for "http://acme.example.com/v1/x" would be handled by `fn`, with
+//   PathParam(r, "tenant") == "acme"
+// A request for "http://example.com/v1/x" would be handled by `fn2`.
+//
+// Middleware can be added for all routes with Use, or for all routes under a
+// path prefix with UsePrefix. It also applies to routes registered afterwards.
+// Middleware can retrieve the pattern of the matched route with RoutePattern.
+//
+// Request paths are routed as they are. SetCleanPath enables redirecting
+// non-canonical paths, like "/a//b/../c", to their cleaned form, and
+// SetTrailingSlash sets how paths differing from a route by a trailing slash
+// only are treated.
+//
+// Routes can be added and removed while the TreeMux is serving requests.
+// Requests are always routed using a consistent view of the routes.
+//
+// Handlers can be registered for a specific HTTP method using HandleMethod.
+// When a path matches, but none of its handlers accepts the request method, the
+// TreeMux responds with 405 Method Not Allowed and an Allow header listing the
+// supported methods. HEAD requests are served by GET handlers and OPTIONS
+// requests are answered automatically, unless handlers were registered for
+// them explicitly.
+//
+// Requests matching no route, requests with an unsupported method and panics
+// while serving are passed on to handlers that can be replaced with
+// SetNotFound (per prefix), SetMethodNotAllowed and SetPanicHandler. By
+// default, panics are logged with their stack trace and answered with 500
+// Internal Server Error. When the response has already started, the request
+// is aborted instead.
+//
 type TreeMux interface {
 	http.Handler
 
@@ -27,38 +128,796 @@ type TreeMux interface {
 	//
 	// The wildcard is a flexible, retrieval-time parameter. It plays no role
 	// whatsoever at construction-time.
+	//
+	// Panics when a named element has an invalid constraint, when an element
+	// has unbalanced braces, like "{n:a/b}" split into "{n:a" and "b}", or when
+	// a catch-all is not the last element.
 	Handle(path string, handler http.Handler)
 
 	// Add a new http.HandlerFunc for the given path. See Handle for more
 	// details.
 	HandleFunc(path string, handler http.HandlerFunc)
+
+	// Add a new http.Handler for the given method and path. Requests for the
+	// path with other methods are served by the handlers registered for those,
+	// or by the method-less handler set with Handle. See Handle for more
+	// details.
+	HandleMethod(method, path string, handler http.Handler)
+
+	// Add a new http.HandlerFunc for the given method and path. See
+	// HandleMethod for more details.
+	HandleMethodFunc(method, path string, handler http.HandlerFunc)
+
+	// Adds middleware for all routes, including the not-found handler. The
+	// first middleware is the outermost one. Middleware added by earlier calls
+	// wraps middleware added by later calls.
+	Use(middleware ...func(http.Handler) http.Handler)
+
+	// Adds middleware for all routes under the given prefix. Elements are
+	// compared literally: the prefix "/admin/" applies to the routes "/admin"
+	// and "/admin/{id}", but not to "/*/users" or "/administrator". Apart from
+	// the root prefix "/", which applies to all routes, prefixes never apply to
+	// routes registered through Host, unless set through Host as well.
+	//
+	// Middleware added with Use is always outermost, followed by the
+	// middleware for shorter prefixes. For the rest, the rules of Use apply.
+	UsePrefix(prefix string, middleware ...func(http.Handler) http.Handler)
+
+	// Sets limits for all routes under the given prefix, which is interpreted
+	// as for UsePrefix. A route is subject to the limits of the longest prefix
+	// covering it that sets them; zero values are inherited from shorter
+	// prefixes. The limits apply within all middleware, so middleware sees the
+	// responses they cause.
+	//
+	// Example:
+	//   t.SetLimits("/", Limits{Timeout: 5 * time.Second, MaxBodySize: 1 << 20})
+	//   t.SetLimits("/upload", Limits{MaxBodySize: 100 << 20})
+	// Requests for "/upload/{id}" may then take 5 seconds and send 100 MiB.
+	SetLimits(prefix string, limits Limits)
+
+	// Sets the handler for requests that match no route. If set to `nil`, the
+	// default http.NotFound will be used. For a group, the not-found handler
+	// only applies to requests under its prefix; setting it to `nil` makes the
+	// group use the handler of the enclosing prefix again.
+	//
+	// The handler can retrieve the prefix it was set for with RouteOutcome.
+	SetNotFound(handler http.HandlerFunc)
+
+	// Sets the handler for requests whose path matches a route that does not
+	// support the request method. The Allow header has already been set; the
+	// supported methods are also available through RouteOutcome. If set to
+	// `nil`, the default handler responds with 405 Method Not Allowed. For a
+	// group, this applies to the TreeMux it belongs to.
+	SetMethodNotAllowed(handler http.HandlerFunc)
+
+	// Sets the handler for panics while serving a request, including those in
+	// middleware. It receives the value passed to panic; RouteOutcome tells
+	// which route was serving the request. If set to `nil`, the default handler
+	// logs the panic with its stack trace and responds with 500 Internal
+	// Server Error. Panics with http.ErrAbortHandler are not recovered. Neither
+	// are panics after the response has started: these are logged, after which
+	// the request is aborted with http.ErrAbortHandler. For a group, this
+	// applies to the TreeMux it belongs to.
+	SetPanicHandler(handler func(w http.ResponseWriter, r *http.Request, v interface{}))
+
+	// Returns a TreeMux for registering routes under the given prefix. The
+	// group shares its routes with the TreeMux it was created from, so serving
+	// a request through either has the same result. Middleware added to the
+	// group only applies to routes under its prefix, as does its not-found
+	// handler.
+	//
+	// Example:
+	//   api := t.Group("/api/v1")
+	//   api.Use(auth)
+	//   api.HandleFunc("/users/{id}", fn)
+	// Would register `fn`, wrapped in `auth`, for "/api/v1/users/{id}".
+	Group(prefix string) TreeMux
+
+	// Returns a TreeMux for registering routes that only match requests for
+	// hosts matching the host pattern. Labels of the host pattern can be
+	// literals, wildcards ("*") or named elements, with or without a
+	// constraint; a constraint cannot accept empty labels. Each label matches
+	// exactly one label of the request host, so "*.example.com" does not match
+	// "example.com" or "a.b.example.com". Literal labels are matched
+	// case-insensitively.
+	// The returned TreeMux behaves like a group: middleware, limits and the
+	// not-found handler added to it only apply to its routes. For a group,
+	// routes are registered under its prefix.
+	//
+	// Panics when the host pattern is invalid.
+	//
+	// Example:
+	//   t.Host("{tenant}.example.com").HandleFunc("/users/{id}", fn)
+	// Would register `fn` for "{tenant}.example.com/users/{id}".
+	Host(pattern string) TreeMux
+
+	// Registers a name for the given path, so URL can build paths from it.
+	// The route itself can be added before or after naming it. When a name
+	// is already in use, the old path is overwritten.
+	Name(name, path string)
+
+	// Builds the path for the named route, filling in its named elements with
+	// the given key-value pairs. Values are escaped, catch-all values element
+	// by element. Returns an error for an unknown name, for missing or extra
+	// parameters and for paths with anonymous wildcards.
+	//
+	// Example:
+	//   t.Name("order", "/users/{id}/orders/{orderId}")
+	//   t.URL("order", "id", "42", "orderId", "7")
+	// Returns "/users/42/orders/7".
+	URL(name string, params ...string) (string, error)
+
+	// Serves the files in the file system under the given prefix, using a
+	// FileServer with the default options: index files are served for
+	// directories, other directories are not found. The route consists of the
+	// prefix followed by a catch-all and accepts GET and HEAD requests.
+	//
+	// Example:
+	//   t.ServeFS("/static", os.DirFS("web"))
+	// A request for "/static/css/a.css" would be served "web/css/a.css".
+	ServeFS(prefix string, fsys fs.FS)
+
+	// Serves the files in the file system under the given prefix, like
+	// ServeFS, but using the given options.
+	ServeFSWithOptions(prefix string, fsys fs.FS, opts FSOptions)
+
+	// Forwards all requests under the given prefix to the target, using a
+	// proxy created by NewProxy. The route consists of the prefix followed by
+	// a catch-all.
+	//
+	// Example:
+	//   t.Proxy("/legacy", target, ProxyOptions{StripPrefix: true})
+	// A request for "/legacy/users?id=1" would be forwarded to
+	// target.Path + "/users?id=1".
+	Proxy(prefix string, target *url.URL, opts ProxyOptions)
+
+	// Calls fn for every route, in order of their paths. Besides the path, fn
+	// receives the sorted methods the route has handlers for, with "*" for a
+	// handler registered without method, and the handler serving the route,
+	// wrapped in its middleware. Walking stops at the first error, which is
+	// then returned. For a group, only the routes under its prefix are walked.
+	Walk(fn func(pattern string, methods []string, h http.Handler) error) error
+
+	// Removes the route for the given path, with all its handlers. Reports
+	// whether there was a route to remove.
+	Remove(path string) bool
+
+	// Removes the handler for the given method and path. A handler registered
+	// without method is removed by passing an empty method. When no handlers
+	// remain, the route is removed altogether. Reports whether there was a
+	// handler to remove.
+	RemoveMethod(method, path string) bool
+
+	// Sets strict mode. In strict mode, registering a handler panics with a
+	// *ConflictError when it would overwrite an existing handler, or when its
+	// path conflicts with that of another route. Paths conflict when some
+	// request would match both, but neither is more specific than the other
+	// for all such requests, like "/a/*/c" and "/a/b/*". Paths matching the
+	// exact same requests, like "/users/{id}" and "/users/{name}", are
+	// duplicates. As "*" also matches empty elements, "/users/{id}" is more
+	// specific than "/users/*".
+	// For a group, this sets strict mode for the TreeMux it belongs to.
+	SetStrict(strict bool)
+
+	// Sets whether request paths are cleaned before routing. Paths with empty,
+	// "." or ".." elements are then redirected to their canonical form, like
+	// "/a//b/../c" to "/a/c". GET and HEAD requests are redirected with 301
+	// Moved Permanently, others with 308 Permanent Redirect. Off by default.
+	// For a group, this applies to the TreeMux it belongs to.
+	SetCleanPath(clean bool)
+
+	// Sets the policy for requests that match no route, but would after adding
+	// or removing a trailing slash: TrailingSlashStrict (the default),
+	// TrailingSlashRedirect or TrailingSlashEquivalent. Redirects use the
+	// status codes of SetCleanPath. For a group, this applies to the TreeMux
+	// it belongs to.
+	SetTrailingSlash(policy TrailingSlash)
+
+	// Checks all routes for conflicting paths, as in strict mode. Returns nil
+	// if there are none, or an error joining a *ConflictError for every pair
+	// of conflicting routes. For a group, only conflicts involving routes under
+	// its prefix are reported.
+	Validate() error
 }
 
 type treeMux struct {
-	trie     *wildcardTrie
-	notFound http.HandlerFunc
+	state atomic.Pointer[muxState]
+
+	// guards the fields below, which describe the routes from which the state
+	// is built
+	mu         sync.RWMutex
+	routes     map[string]*route
+	middleware []func(http.Handler) http.Handler
+	prefixed   []prefixMiddleware
+	limits     []prefixLimits
+	names      map[string]string
+	strict     bool
+
+	// the not-found handlers by prefix, stored under prefix + "/**"
+	notFounds map[string]*route
+
+	// options read while serving
+	cleanPath     atomic.Bool
+	trailingSlash atomic.Int32
+	errors        atomic.Pointer[errorHandlers]
+}
+
+// A muxState holds the tries used for routing. It is never modified; changes
+// to the routes replace it as a whole, so requests can be served without
+// locking.
+type muxState struct {
+	trie         *pathtrie.Trie[*route]
+	notFoundTrie *pathtrie.Trie[*route]
+	// the same for routes with a host pattern, stored under their host key;
+	// nil until the first such route is added
+	hostTrie         *pathtrie.Trie[*route]
+	hostNotFoundTrie *pathtrie.Trie[*route]
 }
 
-func (t treeMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	v, found := t.trie.Get(r.URL.Path, "/")
-	if !found {
-		t.notFound(w, r)
-		return
+// Returns the trie for routes (or not-found handlers) with the pattern and the
+// key to store them under.
+func (s *muxState) locate(pattern string, notFound bool) (**pathtrie.Trie[*route], string) {
+	host, path := splitHost(pattern)
+	switch {
+	case host == "" && notFound:
+		return &s.notFoundTrie, pattern
+	case host == "":
+		return &s.trie, pattern
+	case notFound:
+		return &s.hostNotFoundTrie, hostKey(host, path)
+	}
+	return &s.hostTrie, hostKey(host, path)
+}
+
+// Returns a copy of the state with the route stored under the pattern. A nil
+// route removes it.
+func (s *muxState) with(pattern string, notFound bool, rt *route) *muxState {
+	c := *s
+	trie, k := c.locate(pattern, notFound)
+	if *trie == nil {
+		*trie = pathtrie.New[*route]()
+	} else {
+		*trie = (*trie).Clone()
+	}
+	if rt == nil {
+		(*trie).Delete(k, "/")
+	} else {
+		(*trie).Add(k, "/", rt)
 	}
-	switch h := v.(type) {
-	case http.Handler:
-		h.ServeHTTP(w, r)
-	case http.HandlerFunc:
-		h(w, r)
+	return &c
+}
+
+// Returns whether there are routes or not-found handlers with a host pattern.
+func (s *muxState) hasHosts() bool {
+	return s.hostTrie != nil || s.hostNotFoundTrie != nil
+}
+
+// Finds the route for the host and path. Routes with a host pattern take
+// precedence; the others serve as fallback.
+func (s *muxState) match(host, path string) (*route, []pathtrie.Param, bool) {
+	if host != "" && s.hostTrie != nil {
+		if rt, ps, found := s.hostTrie.Match(hostKey(host, path), "/"); found {
+			return rt, ps, found
+		}
+	}
+	return s.trie.Match(path, "/")
+}
+
+// Finds the not-found handler for the host and path, like match.
+func (s *muxState) matchNotFound(host, path string) (*route, []pathtrie.Param) {
+	// the root not-found handler catches all paths starting with a "/"
+	path = cleanPattern(path)
+	if host != "" && s.hostNotFoundTrie != nil {
+		if rt, ps, found := s.hostNotFoundTrie.Match(hostKey(host, path), "/"); found {
+			return rt, ps
+		}
 	}
+	rt, ps, _ := s.notFoundTrie.Match(path, "/")
+	return rt, ps
+}
+
+type contextKey int
+
+const (
+	paramsKey contextKey = iota
+	outcomeKey
+)
+
+// Returns the values bound to the named elements of the matched route.
+func params(r *http.Request) []pathtrie.Param {
+	ps, _ := r.Context().Value(paramsKey).([]pathtrie.Param)
+	return ps
+}
+
+func (t *treeMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// like http.ServeMux, leave CONNECT and "*" (as in "OPTIONS *") alone
+	canonical := r.Method != http.MethodConnect && r.URL.Path != "*"
+	if canonical && t.cleanPath.Load() {
+		if p := cleanPath(r.URL.Path); p != r.URL.Path {
+			redirectPath(w, r, p)
+			return
+		}
+	}
+	s := t.state.Load()
+	var host string
+	if s.hasHosts() {
+		host = requestHost(r)
+	}
+	rt, ps, found := s.match(host, r.URL.Path)
+	if policy := TrailingSlash(t.trailingSlash.Load()); canonical && !found && policy != TrailingSlashStrict {
+		if p := toggleSlash(r.URL.Path); p != "" {
+			if rt, ps, found = s.match(host, p); found && policy == TrailingSlashRedirect {
+				redirectPath(w, r, p)
+				return
+			}
+		}
+	}
+	var o Outcome
+	if found {
+		o.Pattern = rt.pattern
+	} else {
+		rt, ps = s.matchNotFound(host, r.URL.Path)
+		o.Prefix = strings.TrimSuffix(rt.pattern, pathtrie.CatchAll)
+		r = withOutcome(r, o)
+	}
+	// like http.ServeMux, set the pattern on the request itself, so static
+	// routes are served without allocating for the route
+	r.Pattern = o.Pattern
+	if len(ps) > 0 {
+		r = r.WithContext(context.WithValue(r.Context(), paramsKey, ps))
+	}
+	// tracks whether the response has started, as a panic handler can only
+	// respond before that
+	sw := &statusWriter{ResponseWriter: w}
+	defer func() {
+		if v := recover(); v != nil {
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			if sw.status != 0 {
+				abortPanic(r, v)
+			}
+			t.errors.Load().panic(w, withOutcome(r, o), v)
+		}
+	}()
+	rt.chain.ServeHTTP(sw, r)
 }
 
 func (t *treeMux) Handle(path string, handler http.Handler) {
-	t.trie.Add(path, "/", handler)
+	t.HandleMethod("", path, handler)
 }
 
 func (t *treeMux) HandleFunc(path string, handler http.HandlerFunc) {
-	t.trie.Add(path, "/", handler)
+	t.HandleMethod("", path, handler)
+}
+
+func (t *treeMux) HandleMethod(method, path string, handler http.Handler) {
+	t.handle(method, cleanPattern(path), handler)
+}
+
+// Adds the handler for the method and the (cleaned) pattern.
+func (t *treeMux) handle(method, path string, handler http.Handler) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := checkPattern(path); err != nil {
+		panic(err)
+	}
+	if t.strict {
+		if err := t.checkConflicts(method, path); err != nil {
+			panic(err)
+		}
+	}
+	rt := t.routes[path].with(path, method, handler)
+	rt.chain = t.chain(rt)
+	t.routes[path] = rt
+
+	t.state.Store(t.state.Load().with(path, false, rt))
+}
+
+func (t *treeMux) HandleMethodFunc(method, path string, handler http.HandlerFunc) {
+	t.HandleMethod(method, path, handler)
+}
+
+func (t *treeMux) Remove(path string) bool {
+	return t.removeRoute(cleanPattern(path))
+}
+
+// Removes the route for the (cleaned) pattern.
+func (t *treeMux) removeRoute(path string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.remove(path)
+}
+
+func (t *treeMux) RemoveMethod(method, path string) bool {
+	return t.removeMethod(method, cleanPattern(path))
+}
+
+// Removes the handler for the method and the (cleaned) pattern.
+func (t *treeMux) removeMethod(method, path string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	rt, ok := t.routes[path]
+	if !ok {
+		return false
+	}
+	if _, ok := rt.handlers[method]; !ok {
+		return false
+	}
+	if len(rt.handlers) == 1 {
+		return t.remove(path)
+	}
+	rt = rt.with(path, method, nil)
+	rt.chain = t.chain(rt)
+	t.routes[path] = rt
+
+	t.state.Store(t.state.Load().with(path, false, rt))
+	return true
+}
+
+// Removes the route for the path. Expects the lock to be held.
+func (t *treeMux) remove(path string) bool {
+	if _, ok := t.routes[path]; !ok {
+		return false
+	}
+	delete(t.routes, path)
+
+	t.state.Store(t.state.Load().with(path, false, nil))
+	return true
+}
+
+func (t *treeMux) Use(middleware ...func(http.Handler) http.Handler) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.middleware = append(t.middleware, middleware...)
+	t.rechain()
+}
+
+func (t *treeMux) UsePrefix(prefix string, middleware ...func(http.Handler) http.Handler) {
+	t.usePrefix(cleanPattern(prefix), middleware...)
+}
+
+// Adds middleware for the (cleaned) prefix.
+func (t *treeMux) usePrefix(prefix string, middleware ...func(http.Handler) http.Handler) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.prefixed = append(t.prefixed, newPrefixMiddleware(prefix, middleware))
+	t.rechain()
+}
+
+func (t *treeMux) SetLimits(prefix string, limits Limits) {
+	t.setLimits(cleanPattern(prefix), limits)
+}
+
+// Sets the limits for the (cleaned) prefix.
+func (t *treeMux) setLimits(prefix string, limits Limits) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.limits = append(t.limits, prefixLimits{prefix: newPathPrefix(prefix), limits: limits})
+	t.rechain()
+}
+
+func (t *treeMux) SetNotFound(handler http.HandlerFunc) {
+	if handler == nil {
+		handler = http.NotFound
+	}
+	t.setNotFound("", handler)
+}
+
+// Sets the not-found handler for the prefix. A nil handler removes it, or
+// resets it to http.NotFound for the root.
+func (t *treeMux) setNotFound(prefix string, handler http.Handler) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p := joinPattern(prefix, pathtrie.CatchAll)
+	if handler == nil && p == "/"+pathtrie.CatchAll {
+		// the root not-found handler cannot be removed, only reset
+		handler = http.HandlerFunc(http.NotFound)
+	}
+	var rt *route
+	if handler == nil {
+		delete(t.notFounds, p)
+	} else {
+		rt = (*route)(nil).with(p, "", handler)
+		rt.chain = t.chain(rt)
+		t.notFounds[p] = rt
+	}
+
+	t.state.Store(t.state.Load().with(p, true, rt))
+}
+
+func (t *treeMux) SetMethodNotAllowed(handler http.HandlerFunc) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	c := *t.errors.Load()
+	c.methodNotAllowed = handler
+	if handler == nil {
+		c.methodNotAllowed = http.HandlerFunc(defaultMethodNotAllowed)
+	}
+	t.errors.Store(&c)
+}
+
+func (t *treeMux) SetPanicHandler(handler func(w http.ResponseWriter, r *http.Request, v interface{})) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	c := *t.errors.Load()
+	c.panic = handler
+	if handler == nil {
+		c.panic = defaultPanicHandler
+	}
+	t.errors.Store(&c)
+}
+
+func (t *treeMux) SetStrict(strict bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.strict = strict
+}
+
+func (t *treeMux) SetCleanPath(clean bool) {
+	t.cleanPath.Store(clean)
+}
+
+func (t *treeMux) SetTrailingSlash(policy TrailingSlash) {
+	t.trailingSlash.Store(int32(policy))
+}
+
+// Checks whether a handler for the method and path can be added without
+// overwriting another or conflicting with other routes.
+func (t *treeMux) checkConflicts(method, path string) error {
+	if rt, ok := t.routes[path]; ok {
+		if _, ok := rt.handlers[method]; ok {
+			return &ConflictError{Pattern: path, Other: path, Duplicate: true}
+		}
+		return nil
+	}
+	ps := make([]string, 0, len(t.routes))
+	for p := range t.routes {
+		ps = append(ps, p)
+	}
+	sort.Strings(ps)
+	for _, p := range ps {
+		if err := checkConflict(path, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *treeMux) Validate() error {
+	return t.validate(nil)
+}
+
+// Checks the routes for conflicts with at least one route covered by the
+// prefix, or all routes for a nil prefix.
+func (t *treeMux) validate(prefix pathPrefix) error {
+	t.mu.RLock()
+	ps := make([]string, 0, len(t.routes))
+	for p := range t.routes {
+		ps = append(ps, p)
+	}
+	t.mu.RUnlock()
+
+	sort.Strings(ps)
+	var errs []error
+	for i := range ps {
+		for j := i + 1; j < len(ps); j += 1 {
+			if prefix != nil && !prefix.covers(ps[i]) && !prefix.covers(ps[j]) {
+				continue
+			}
+			if err := checkConflict(ps[i], ps[j]); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (t *treeMux) Name(name, path string) {
+	t.name(name, cleanPattern(path))
+}
+
+// Names the (cleaned) pattern.
+func (t *treeMux) name(name, path string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.names[name] = path
+}
+
+func (t *treeMux) URL(name string, params ...string) (string, error) {
+	t.mu.RLock()
+	p, ok := t.names[name]
+	t.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("unknown route %q", name)
+	}
+	return buildURL(p, params)
+}
+
+func (t *treeMux) Walk(fn func(pattern string, methods []string, h http.Handler) error) error {
+	return t.walk(nil, fn)
+}
+
+// Walks the routes covered by the prefix, or all routes for a nil prefix. The
+// routes are collected first, so fn is free to modify them.
+func (t *treeMux) walk(prefix pathPrefix, fn func(pattern string, methods []string, h http.Handler) error) error {
+	t.mu.RLock()
+	rts := make([]*route, 0, len(t.routes))
+	for p, rt := range t.routes {
+		if prefix == nil || prefix.covers(p) {
+			rts = append(rts, rt)
+		}
+	}
+	t.mu.RUnlock()
+
+	sort.Slice(rts, func(i, j int) bool {
+		return rts[i].pattern < rts[j].pattern
+	})
+	for _, rt := range rts {
+		if err := fn(rt.pattern, rt.methods(), rt.chain); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *treeMux) ServeFS(prefix string, fsys fs.FS) {
+	t.ServeFSWithOptions(prefix, fsys, FSOptions{})
+}
+
+func (t *treeMux) ServeFSWithOptions(prefix string, fsys fs.FS, opts FSOptions) {
+	t.serveFS(cleanPattern(prefix), fsys, opts)
+}
+
+// Serves the file system under the (cleaned) prefix.
+func (t *treeMux) serveFS(prefix string, fsys fs.FS, opts FSOptions) {
+	t.handle(http.MethodGet, joinPattern(prefix, pathtrie.CatchAll), FileServer(fsys, opts))
+}
+
+func (t *treeMux) Proxy(prefix string, target *url.URL, opts ProxyOptions) {
+	t.proxy(cleanPattern(prefix), target, opts)
+}
+
+// Forwards the requests under the (cleaned) prefix to the target.
+func (t *treeMux) proxy(prefix string, target *url.URL, opts ProxyOptions) {
+	t.handle("", joinPattern(prefix, pathtrie.CatchAll), NewProxy(target, opts))
+}
+
+func (t *treeMux) Group(prefix string) TreeMux {
+	return &group{root: t, prefix: cleanPattern(prefix)}
+}
+
+func (t *treeMux) Host(pattern string) TreeMux {
+	return &group{root: t, prefix: hostPattern(pattern)}
+}
+
+// Wraps the route in its limits and the middleware that applies to it.
+func (t *treeMux) chain(rt *route) http.Handler {
+	h := limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rt.serve(w, r, t.errors.Load().methodNotAllowed)
+	}), collectLimits(rt.pattern, t.limits))
+	return chain(h, collectMiddleware(rt.pattern, t.middleware, t.prefixed))
+}
+
+// Replaces all routes and not-found handlers with copies wrapped in their
+// current middleware.
+func (t *treeMux) rechain() {
+	for _, rts := range []map[string]*route{t.routes, t.notFounds} {
+		for p, rt := range rts {
+			c := *rt
+			c.chain = t.chain(&c)
+			rts[p] = &c
+		}
+	}
+	t.rebuild()
+}
+
+// Replaces the state with one built from the current routes.
+func (t *treeMux) rebuild() {
+	s := &muxState{trie: pathtrie.New[*route](), notFoundTrie: pathtrie.New[*route]()}
+	for _, x := range []struct {
+		notFound bool
+		rts      map[string]*route
+	}{{false, t.routes}, {true, t.notFounds}} {
+		ps := make([]string, 0, len(x.rts))
+		for p := range x.rts {
+			ps = append(ps, p)
+		}
+		sort.Strings(ps)
+		for _, p := range ps {
+			trie, k := s.locate(p, x.notFound)
+			if *trie == nil {
+				*trie = pathtrie.New[*route]()
+			}
+			(*trie).Add(k, "/", x.rts[p])
+		}
+	}
+	t.state.Store(s)
+}
+
+// Returns the pattern with its leading separator, so equivalent patterns map
+// to the same route.
+func cleanPattern(p string) string {
+	if p == "" || strings.HasPrefix(p, "/") {
+		return p
+	}
+	return "/" + p
+}
+
+// Checks whether the pattern can be matched: its braces must be balanced
+// within each element, the constraints of its named elements must compile, as
+// must its host pattern, and a catch-all can only be the last element.
+func checkPattern(p string) error {
+	host, path := splitHost(p)
+	if err := checkBraces(host, "."); err != nil {
+		return err
+	}
+	if err := checkBraces(path, "/"); err != nil {
+		return err
+	}
+	if host != "" {
+		if err := checkHost(host); err != nil {
+			return err
+		}
+	}
+	xs := strings.Split(path, "/")
+	for i, x := range xs {
+		if _, ok := pathtrie.CatchAllName(x); ok && i < len(xs)-1 {
+			return fmt.Errorf("catch-all %q is not the last element of %q", x, p)
+		}
+	}
+	for _, x := range append(strings.Split(host, "."), xs...) {
+		if c := pathtrie.ParamConstraint(x); c != "" {
+			if _, err := pathtrie.CompileConstraint(c); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Checks that no element has unbalanced braces. As elements are split on the
+// separator first, a constraint cannot contain it.
+func checkBraces(s, sep string) error {
+	depth := 0
+	for i := 0; i < len(s); i += 1 {
+		switch {
+		case s[i] == '{':
+			depth += 1
+		case s[i] == '}':
+			depth -= 1
+			if depth < 0 {
+				return fmt.Errorf("unbalanced %q in %q", "}", s)
+			}
+		case depth > 0 && strings.HasPrefix(s[i:], sep):
+			return fmt.Errorf("constraint contains separator %q in %q", sep, s)
+		}
+	}
+	if depth > 0 {
+		return fmt.Errorf("unbalanced %q in %q", "{", s)
+	}
+	return nil
+}
+
+// Appends the pattern to the (cleaned) prefix, so that they are separated by
+// exactly one separator.
+func joinPattern(prefix, p string) string {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if p == "" {
+		return prefix
+	}
+	return prefix + cleanPattern(p)
 }
 
 // Creates a new tree-based request multiplexer. If a request cannot be matched,
@@ -71,11 +930,80 @@ func NewTreeMux() TreeMux {
 // the specified HandlerFunc will be used. If set to `nil`, the default
 // http.NotFound will be used.
 func NewTreeMuxWithNotFound(notFound http.HandlerFunc) TreeMux {
-	if notFound == nil {
-		notFound = http.NotFound
+	t := &treeMux{
+		routes:    map[string]*route{},
+		names:     map[string]string{},
+		notFounds: map[string]*route{},
 	}
-	return &treeMux{
-		trie:     newWildcardTrie(),
-		notFound: notFound,
+	t.errors.Store(&errorHandlers{
+		methodNotAllowed: http.HandlerFunc(defaultMethodNotAllowed),
+		panic:            defaultPanicHandler,
+	})
+	t.rebuild()
+	t.SetNotFound(notFound)
+	return t
+}
+
+// Returns the pattern of the route the TreeMux matched the request to, like
+// "/users/{id}". Returns an empty string if no route matched. The pattern is
+// available to middleware as well as to handlers. The TreeMux sets it as the
+// request's Pattern, replacing the pattern of any enclosing http.ServeMux.
+func RoutePattern(r *http.Request) string {
+	return r.Pattern
+}
+
+// Returns the value the named path element ("{name}") matched for this request.
+// Returns an empty string if the matched route has no element by that name.
+func PathParam(r *http.Request, name string) string {
+	for _, p := range params(r) {
+		if p.Name == name {
+			return p.Value
+		}
+	}
+	return ""
+}
+
+// Returns the value of the named element with the given name, as converted by
+// its constraint: an int64 for "int", a time.Time for "date" and a string for
+// regular expressions. The second return value is false if the route has no
+// such element, or if it has no constraint yielding a T.
+func PathParamAs[T any](r *http.Request, name string) (T, bool) {
+	for _, p := range params(r) {
+		if p.Name == name {
+			v, ok := p.Typed.(T)
+			return v, ok
+		}
 	}
+	var zero T
+	return zero, false
+}
+
+// Returns the remainder of the path matched by the catch-all element ("**" or
+// "{name...}") of the route. Returns an empty string if the route has no
+// catch-all or if it matched nothing.
+func PathRemainder(r *http.Request) string {
+	ps := params(r)
+	if len(ps) > 0 && ps[len(ps)-1].Rest {
+		return ps[len(ps)-1].Value
+	}
+	return ""
+}
+
+// Renders the routes of the TreeMux as a table with a row per route, listing
+// its methods and path. Meant for startup logs and debugging.
+//
+// Example:
+//   METHODS    PATH
+//   GET, POST  /items/*
+//   *          /users/{id}
+func RouteTable(t TreeMux) string {
+	b := &strings.Builder{}
+	w := tabwriter.NewWriter(b, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "METHODS\tPATH")
+	_ = t.Walk(func(pattern string, methods []string, _ http.Handler) error {
+		_, err := fmt.Fprintf(w, "%s\t%s\n", strings.Join(methods, ", "), pattern)
+		return err
+	})
+	_ = w.Flush()
+	return b.String()
 }
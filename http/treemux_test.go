@@ -1,10 +1,16 @@
 package http
 
 import (
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 type testHandler struct {
@@ -19,7 +25,7 @@ func TestTreeMux_Handle(t *testing.T) {
 		_, _ = w.Write([]byte("foo!bar!"))
 	}
 
-	tr := NewTreeMux(nil)
+	tr := NewTreeMux()
 	tr.HandleFunc("foo/bar", handleFunc)
 	tr.HandleFunc("/moo", handleFunc)
 	tr.Handle("/moo/", testHandler{})
@@ -57,3 +63,1024 @@ func TestTreeMux_Handle(t *testing.T) {
 		}
 	}
 }
+
+func TestTreeMux_PathParam(t *testing.T) {
+	handleFunc := func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(PathParam(r, "id") + "|" + PathParam(r, "orderId")))
+	}
+
+	tr := NewTreeMux()
+	tr.HandleFunc("/users/{id}", handleFunc)
+	tr.HandleFunc("/users/{id}/orders/{orderId}", handleFunc)
+	tr.HandleFunc("/static", handleFunc)
+
+	cases := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/users/42", 200, "42|"},
+		{"/users/42/orders/7", 200, "42|7"},
+		{"/users/42/orders", 404, ""},
+		{"/static", 200, "|"},
+	}
+	for i, c := range cases {
+		w := httptest.NewRecorder()
+		tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+		if w.Code != c.code {
+			t.Errorf("%v %s: expected %v, got %v", i, c.path, c.code, w.Code)
+			continue
+		}
+		if c.code != 200 {
+			continue
+		}
+		if w.Body.String() != c.body {
+			t.Errorf("%v %s: expected %s, got %v", i, c.path, c.body, w.Body.String())
+		}
+	}
+}
+
+func TestTreeMux_PathParamAs(t *testing.T) {
+	tr := NewTreeMux()
+	tr.HandleFunc("/orders/{id:int}", func(w http.ResponseWriter, r *http.Request) {
+		id, ok := PathParamAs[int64](r, "id")
+		_, _ = fmt.Fprintf(w, "order %d %v", id, ok)
+	})
+	tr.HandleFunc("/orders/{ref}", func(w http.ResponseWriter, r *http.Request) {
+		_, ok := PathParamAs[string](r, "ref")
+		_, _ = fmt.Fprintf(w, "ref %s %v", PathParam(r, "ref"), ok)
+	})
+	tr.HandleFunc("/files/{name:[a-z0-9_-]+}", func(w http.ResponseWriter, r *http.Request) {
+		name, ok := PathParamAs[string](r, "name")
+		_, _ = fmt.Fprintf(w, "file %s %v", name, ok)
+	})
+	tr.HandleFunc("/at/{date:date}", func(w http.ResponseWriter, r *http.Request) {
+		d, ok := PathParamAs[time.Time](r, "date")
+		_, _ = fmt.Fprintf(w, "at %s %v", d.Format("Jan 2 2006"), ok)
+	})
+
+	cases := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/orders/42", 200, "order 42 true"},
+		{"/orders/abc", 200, "ref abc false"},
+		{"/files/a_b-1", 200, "file a_b-1 true"},
+		{"/files/A.txt", 404, ""},
+		{"/at/2024-02-29", 200, "at Feb 29 2024 true"},
+		{"/at/tomorrow", 404, ""},
+	}
+	for i, c := range cases {
+		w := httptest.NewRecorder()
+		tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+		if w.Code != c.code {
+			t.Errorf("%v %s: expected %v, got %v", i, c.path, c.code, w.Code)
+			continue
+		}
+		if c.code != 200 {
+			continue
+		}
+		if w.Body.String() != c.body {
+			t.Errorf("%v %s: expected %s, got %v", i, c.path, c.body, w.Body.String())
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic for invalid constraint")
+		}
+	}()
+	tr.HandleFunc("/files/{name:[a-z}/x", func(w http.ResponseWriter, r *http.Request) {})
+}
+
+func TestCheckPattern(t *testing.T) {
+	cases := []struct {
+		pattern string
+		err     bool
+	}{
+		{"/a/{id:int}/b", false},
+		{"/a/**", false},
+		{"/a/{rest...}", false},
+		{"/a/{x:[a-z}", true},
+		{"/a/**/b", true},
+		{"/a/{rest...}/x", true},
+		{"/a/**/", true},
+		{"/a/{id:[0-9]{2}}", false},
+		{"/b/{n:a/b}", true},
+		{"/a/{x", true},
+		{"/a/x}", true},
+		{"/a/}{x}", true},
+		{"{sub:a.b}.example.com/a", true},
+	}
+	for i, c := range cases {
+		if err := checkPattern(c.pattern); (err != nil) != c.err {
+			t.Errorf("%v %s: expected error %v, got %v", i, c.pattern, c.err, err)
+		}
+	}
+
+	tr := NewTreeMux()
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic for catch-all before the last element")
+		}
+	}()
+	tr.HandleFunc("/a/**/b", func(w http.ResponseWriter, r *http.Request) {})
+}
+
+func TestTreeMux_HandleMethod(t *testing.T) {
+	handler := func(s string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(s))
+		}
+	}
+
+	tr := NewTreeMux()
+	tr.HandleMethodFunc(http.MethodGet, "/items/*", handler("get"))
+	tr.HandleMethodFunc(http.MethodPost, "/items/*", handler("post"))
+	tr.HandleMethodFunc(http.MethodDelete, "/items/*", handler("delete"))
+	tr.HandleMethodFunc(http.MethodPut, "/any", handler("put"))
+	tr.HandleFunc("/any", handler("any"))
+	tr.HandleMethodFunc(http.MethodOptions, "/opts", handler("options"))
+	tr.HandleMethodFunc(http.MethodPost, "/opts", handler("post"))
+
+	cases := []struct {
+		method string
+		path   string
+		code   int
+		body   string
+		allow  string
+	}{
+		{http.MethodGet, "/items/1", 200, "get", ""},
+		{http.MethodPost, "/items/1", 200, "post", ""},
+		{http.MethodDelete, "/items/1", 200, "delete", ""},
+		{http.MethodHead, "/items/1", 200, "get", ""},
+		{http.MethodPut, "/items/1", 405, "", "DELETE, GET, HEAD, OPTIONS, POST"},
+		{http.MethodOptions, "/items/1", 204, "", "DELETE, GET, HEAD, OPTIONS, POST"},
+		{http.MethodGet, "/items", 404, "", ""},
+		{http.MethodPut, "/any", 200, "put", ""},
+		{http.MethodPatch, "/any", 200, "any", ""},
+		{http.MethodOptions, "/any", 200, "any", ""},
+		{http.MethodOptions, "/opts", 200, "options", ""},
+		{http.MethodGet, "/opts", 405, "", "OPTIONS, POST"},
+	}
+	for i, c := range cases {
+		w := httptest.NewRecorder()
+		tr.ServeHTTP(w, httptest.NewRequest(c.method, c.path, nil))
+		if w.Code != c.code {
+			t.Errorf("%v %s %s: expected %v, got %v", i, c.method, c.path, c.code, w.Code)
+			continue
+		}
+		if allow := w.Header().Get("Allow"); allow != c.allow {
+			t.Errorf("%v %s %s: expected Allow %q, got %q", i, c.method, c.path, c.allow, allow)
+		}
+		if c.code != 200 {
+			continue
+		}
+		if w.Body.String() != c.body {
+			t.Errorf("%v %s %s: expected %s, got %v", i, c.method, c.path, c.body, w.Body.String())
+		}
+	}
+}
+
+func TestTreeMux_PathRemainder(t *testing.T) {
+	handleFunc := func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(PathRemainder(r) + "|" + PathParam(r, "file")))
+	}
+
+	tr := NewTreeMux()
+	tr.HandleFunc("/static/{file...}", handleFunc)
+	tr.HandleFunc("/proxy/**", handleFunc)
+
+	cases := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/static", 200, "|"},
+		{"/static/css/a.css", 200, "css/a.css|css/a.css"},
+		{"/proxy/", 200, "|"},
+		{"/proxy/a/b", 200, "a/b|"},
+		{"/other", 404, ""},
+	}
+	for i, c := range cases {
+		w := httptest.NewRecorder()
+		tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+		if w.Code != c.code {
+			t.Errorf("%v %s: expected %v, got %v", i, c.path, c.code, w.Code)
+			continue
+		}
+		if c.code != 200 {
+			continue
+		}
+		if w.Body.String() != c.body {
+			t.Errorf("%v %s: expected %s, got %v", i, c.path, c.body, w.Body.String())
+		}
+	}
+}
+
+func TestTreeMux_Host(t *testing.T) {
+	handler := func(s string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(s + PathParam(r, "tenant")))
+		}
+	}
+
+	tr := NewTreeMux()
+	tr.Host("api.example.com").HandleFunc("/v1/*", handler("api"))
+	tr.Host("{tenant}.example.com").HandleFunc("/v1/*", handler("tenant "))
+	tr.Host("{any}.example.com").HandleFunc("/v2", handler("any"))
+	tr.Host("Shop.Example.COM").HandleFunc("/cart", handler("shop"))
+	tr.Host("*.tenant.example.com").HandleFunc("/v1/*", handler("wildcard"))
+	tr.HandleFunc("/v1/*", handler("fallback"))
+	tr.HandleFunc("/v2", handler("fallback v2"))
+	tr.HandleFunc("favicon.ico", handler("icon"))
+	tr.HandleFunc("v1.2/items", handler("dotted"))
+	g := tr.Host("admin.example.com")
+	g.HandleFunc("/home", handler("admin"))
+	g.SetNotFound(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	tr.Group("/api").Host("api.example.com").HandleFunc("/items", handler("api items"))
+
+	cases := []struct {
+		host string
+		path string
+		code int
+		body string
+	}{
+		{"api.example.com", "/v1/x", 200, "api"},
+		{"API.Example.com:8080", "/v1/x", 200, "api"},
+		{"acme.example.com", "/v1/x", 200, "tenant acme"},
+		{"acme.example.com.", "/v1/x", 200, "tenant acme"},
+		{"api.example.com", "/v2", 200, "any"},
+		{"shop.example.com", "/cart", 200, "shop"},
+		{"a.b.example.com", "/v1/x", 200, "fallback"},
+		{"acme.tenant.example.com", "/v1/x", 200, "wildcard"},
+		{"tenant.example.com", "/v1/x", 200, "tenant tenant"},
+		{"a.b.tenant.example.com", "/v1/x", 200, "fallback"},
+		{"example.com", "/v1/x", 200, "fallback"},
+		{"example.com", "/v2", 200, "fallback v2"},
+		{"127.0.0.1:8080", "/v1/x", 200, "fallback"},
+		{"[::1]:8080", "/v1/x", 200, "fallback"},
+		{"api.example.com", "/v3", 404, ""},
+		{"admin.example.com", "/home", 200, "admin"},
+		{"admin.example.com", "/v3", 418, ""},
+		{"admin.example.com", "/v1/x", 200, "tenant admin"},
+		{"api.example.com", "/api/items", 200, "api items"},
+		{"example.com", "/api/items", 404, ""},
+		// host labels and path elements cannot be mixed up
+		{"api.example", "/com/v1/x", 404, ""},
+		{"acme.example", "/com/v1/x", 404, ""},
+		{"api.example", "/com//v1/x", 404, ""},
+		// patterns without leading separator are paths, dots or not
+		{"example.com", "/favicon.ico", 200, "icon"},
+		{"api.example.com", "/favicon.ico", 200, "icon"},
+		{"example.com", "/v1.2/items", 200, "dotted"},
+	}
+	for i, c := range cases {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, c.path, nil)
+		r.Host = c.host
+		tr.ServeHTTP(w, r)
+		if w.Code != c.code {
+			t.Errorf("%v %s%s: expected %v, got %v", i, c.host, c.path, c.code, w.Code)
+			continue
+		}
+		if c.code == 200 && w.Body.String() != c.body {
+			t.Errorf("%v %s%s: expected %s, got %s", i, c.host, c.path, c.body, w.Body.String())
+		}
+	}
+
+	for _, p := range []string{"**.example.com", "a..example.com", "{sub:[a-z]*}.example.com", "a.com/x", ""} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected panic", p)
+				}
+			}()
+			tr.Host(p)
+		}()
+	}
+
+	if !tr.Host("api.example.com").Remove("/v1/*") {
+		t.Errorf("expected route to be removed")
+	}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/v1/x", nil)
+	r.Host = "api.example.com"
+	tr.ServeHTTP(w, r)
+	if w.Body.String() != "tenant api" {
+		t.Errorf("expected tenant route, got %s", w.Body.String())
+	}
+
+	table := RouteTable(tr)
+	for _, p := range []string{"/favicon.ico", "/v1.2/items", "shop.example.com/cart", "api.example.com/api/items"} {
+		if !strings.Contains(table, "  "+p+"\n") {
+			t.Errorf("expected %s in route table, got\n%s", p, table)
+		}
+	}
+}
+
+func TestTreeMux_Use(t *testing.T) {
+	mw := func(s string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(s + ">"))
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	handleFunc := func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("h"))
+	}
+
+	tr := NewTreeMuxWithNotFound(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("nf"))
+	})
+	tr.HandleFunc("/admin", handleFunc)
+	tr.UsePrefix("/admin/users/", mw("users"))
+	tr.UsePrefix("/admin/", mw("admin1"), mw("admin2"))
+	tr.Use(mw("g1"), mw("g2"))
+	tr.Use(mw("g3"))
+	tr.HandleFunc("/admin/users/{id}", handleFunc)
+	tr.HandleFunc("/administrator", handleFunc)
+	tr.HandleFunc("/public", handleFunc)
+
+	cases := []struct {
+		path string
+		body string
+	}{
+		{"/admin", "g1>g2>g3>admin1>admin2>h"},
+		{"/admin/users/1", "g1>g2>g3>admin1>admin2>users>h"},
+		{"/administrator", "g1>g2>g3>h"},
+		{"/public", "g1>g2>g3>h"},
+		{"/nope", "g1>g2>g3>nf"},
+	}
+	for i, c := range cases {
+		w := httptest.NewRecorder()
+		tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+		if w.Body.String() != c.body {
+			t.Errorf("%v %s: expected %s, got %v", i, c.path, c.body, w.Body.String())
+		}
+	}
+
+	// the root prefix also applies to routes registered through Host
+	tr.Host("api.example.com").HandleFunc("/admin", handleFunc)
+	tr.UsePrefix("/", mw("root"))
+	r := httptest.NewRequest(http.MethodGet, "/admin", nil)
+	r.Host = "api.example.com"
+	w := httptest.NewRecorder()
+	tr.ServeHTTP(w, r)
+	if exp := "g1>g2>g3>root>h"; w.Body.String() != exp {
+		t.Errorf("expected %s, got %v", exp, w.Body.String())
+	}
+}
+
+func TestTreeMux_Use_KeepsTies(t *testing.T) {
+	tr := NewTreeMux()
+	tr.HandleFunc("/u/{b}", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("b"))
+	})
+	tr.HandleFunc("/u/{a}", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("a"))
+	})
+	serve := func() string {
+		w := httptest.NewRecorder()
+		tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/u/1", nil))
+		return w.Body.String()
+	}
+
+	before := serve()
+	tr.Use(func(next http.Handler) http.Handler { return next })
+	if after := serve(); after != before {
+		t.Errorf("expected %s after Use, got %s", before, after)
+	}
+}
+
+func TestTreeMux_RoutePattern(t *testing.T) {
+	var act []string
+	tr := NewTreeMux()
+	tr.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			act = append(act, RoutePattern(r))
+			next.ServeHTTP(w, r)
+		})
+	})
+	tr.HandleFunc("/users/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	tr.Group("/api").HandleFunc("/items/**", func(w http.ResponseWriter, r *http.Request) {})
+
+	for _, p := range []string{"/users/42", "/api/items/a/b", "/nope"} {
+		tr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, p, nil))
+	}
+	if exp := []string{"/users/{id}", "/api/items/**", ""}; !reflect.DeepEqual(act, exp) {
+		t.Errorf("expected %v, got %v", exp, act)
+	}
+}
+
+func TestTreeMux_Group(t *testing.T) {
+	mw := func(s string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(s + ">"))
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	handler := func(s string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(s + PathParam(r, "id")))
+		}
+	}
+
+	tr := NewTreeMuxWithNotFound(handler("nf"))
+	tr.Use(mw("g"))
+	api := tr.Group("/api/v1/")
+	api.Use(mw("api"))
+	api.SetNotFound(handler("api-nf"))
+	api.HandleFunc("/users/{id}", handler("user"))
+	users := api.Group("users")
+	users.Use(mw("users"))
+	users.HandleMethodFunc(http.MethodPost, "", handler("create"))
+	tmp := tr.Group("/tmp")
+	tmp.SetNotFound(handler("tmp-nf"))
+	tmp.SetNotFound(nil)
+
+	cases := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{http.MethodGet, "/api/v1/users/42", "g>api>users>user42"},
+		{http.MethodPost, "/api/v1/users", "g>api>users>create"},
+		{http.MethodGet, "/api/v1/nope", "g>api>api-nf"},
+		{http.MethodGet, "/api/v1", "g>api>api-nf"},
+		{http.MethodGet, "/api/v2", "g>nf"},
+		{http.MethodGet, "/tmp/x", "g>nf"},
+	}
+	for i, c := range cases {
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, httptest.NewRequest(c.method, c.path, nil))
+		if w.Body.String() != c.body {
+			t.Errorf("%v %s: expected %s, got %v", i, c.path, c.body, w.Body.String())
+		}
+	}
+}
+
+func TestTreeMux_Group_ResetRootNotFound(t *testing.T) {
+	for _, prefix := range []string{"", "/"} {
+		tr := NewTreeMuxWithNotFound(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		})
+		tr.Group(prefix).SetNotFound(nil)
+
+		w := httptest.NewRecorder()
+		tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/nope", nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%q: expected %v, got %v", prefix, http.StatusNotFound, w.Code)
+		}
+	}
+}
+
+func TestTreeMux_URL(t *testing.T) {
+	tr := NewTreeMux()
+	tr.Name("order", "/users/{id}/orders/{orderId}")
+	tr.Name("static", "static/{file...}")
+	tr.Name("any", "/items/*")
+	tr.Group("/api").Name("item", "/items/{id}")
+	tr.Name("typed", "/orders/{id:int}")
+	tr.Host("{tenant}.example.com").Name("host", "/items/{id}")
+
+	cases := []struct {
+		name   string
+		params []string
+		exp    string
+		err    bool
+	}{
+		{"order", []string{"id", "42", "orderId", "7"}, "/users/42/orders/7", false},
+		{"order", []string{"orderId", "7", "id", "a b/c"}, "/users/a%20b%2Fc/orders/7", false},
+		{"order", []string{"id", "42"}, "", true},
+		{"order", []string{"id", "", "orderId", "7"}, "", true},
+		{"order", []string{"id", "42", "orderId", "7", "x", "1"}, "", true},
+		{"order", []string{"id", "42", "orderId"}, "", true},
+		{"order", []string{"id", "42", "id", "43", "orderId", "7"}, "", true},
+		{"static", []string{"file", "css/a b.css"}, "/static/css/a%20b.css", false},
+		{"static", []string{"file", ""}, "/static/", false},
+		{"any", nil, "", true},
+		{"item", []string{"id", "1"}, "/api/items/1", false},
+		{"nope", nil, "", true},
+		{"typed", []string{"id", "42"}, "/orders/42", false},
+		{"typed", []string{"id", "abc"}, "", true},
+		{"host", []string{"tenant", "acme", "id", "1"}, "//acme.example.com/items/1", false},
+		{"host", []string{"tenant", "a.b", "id", "1"}, "", true},
+	}
+	for i, c := range cases {
+		act, err := tr.URL(c.name, c.params...)
+		if (err != nil) != c.err {
+			t.Errorf("%v %s: expected error %v, got %v", i, c.name, c.err, err)
+			continue
+		}
+		if act != c.exp {
+			t.Errorf("%v %s: expected %s, got %s", i, c.name, c.exp, act)
+		}
+	}
+}
+
+func TestTreeMux_Walk(t *testing.T) {
+	handleFunc := func(w http.ResponseWriter, r *http.Request) {}
+
+	tr := NewTreeMux()
+	tr.HandleMethodFunc(http.MethodPost, "/items/*", handleFunc)
+	tr.HandleMethodFunc(http.MethodGet, "/items/*", handleFunc)
+	tr.HandleFunc("/users/{id}", handleFunc)
+	tr.HandleMethodFunc(http.MethodPut, "/users/{id}", handleFunc)
+	tr.Group("/api").HandleFunc("/a", handleFunc)
+
+	type walked struct {
+		pattern string
+		methods []string
+	}
+	expected := []walked{
+		{"/api/a", []string{"*"}},
+		{"/items/*", []string{"GET", "POST"}},
+		{"/users/{id}", []string{"*", "PUT"}},
+	}
+	var actual []walked
+	err := tr.Walk(func(pattern string, methods []string, h http.Handler) error {
+		if h == nil {
+			t.Errorf("%s: expected handler", pattern)
+		}
+		actual = append(actual, walked{pattern, methods})
+		return nil
+	})
+	if err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+
+	actual = nil
+	_ = tr.Group("/api").Walk(func(pattern string, methods []string, h http.Handler) error {
+		actual = append(actual, walked{pattern, methods})
+		return nil
+	})
+	if !reflect.DeepEqual(actual, expected[:1]) {
+		t.Errorf("expected %v, got %v", expected[:1], actual)
+	}
+
+	count := 0
+	stop := errors.New("stop")
+	err = tr.Walk(func(string, []string, http.Handler) error {
+		count += 1
+		return stop
+	})
+	if err != stop || count != 1 {
+		t.Errorf("expected to stop after first error, got %v after %v", err, count)
+	}
+}
+
+func TestRouteTable(t *testing.T) {
+	handleFunc := func(w http.ResponseWriter, r *http.Request) {}
+
+	tr := NewTreeMux()
+	tr.HandleMethodFunc(http.MethodGet, "/items/*", handleFunc)
+	tr.HandleMethodFunc(http.MethodPost, "/items/*", handleFunc)
+	tr.HandleFunc("/users/{id}", handleFunc)
+
+	expected := "METHODS    PATH\n" +
+		"GET, POST  /items/*\n" +
+		"*          /users/{id}\n"
+	if act := RouteTable(tr); act != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, act)
+	}
+}
+
+func TestTreeMux_Concurrent(t *testing.T) {
+	handleFunc := func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}
+	mw := func(next http.Handler) http.Handler {
+		return next
+	}
+
+	tr := NewTreeMux()
+	tr.HandleFunc("/stable", handleFunc)
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i += 1 {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j += 1 {
+				p := fmt.Sprintf("/dyn/%v/%v", i, j)
+				tr.HandleMethodFunc(http.MethodGet, p, handleFunc)
+				tr.Group("/grp").HandleFunc(p, handleFunc)
+				if j%10 == 0 {
+					tr.UsePrefix("/dyn", mw)
+					tr.Group(p).SetNotFound(handleFunc)
+				}
+				_ = tr.Walk(func(string, []string, http.Handler) error { return nil })
+				tr.Remove(p)
+			}
+		}(i)
+	}
+	for i := 0; i < 4; i += 1 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				w := httptest.NewRecorder()
+				tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stable", nil))
+				if w.Code != 200 {
+					t.Errorf("expected 200, got %v", w.Code)
+					return
+				}
+				tr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/dyn/1/1", nil))
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(done)
+	wg.Wait()
+
+	if !tr.Remove("/stable") {
+		t.Errorf("expected route to be removed")
+	}
+	if tr.Remove("/stable") {
+		t.Errorf("expected route to be gone")
+	}
+	w := httptest.NewRecorder()
+	tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stable", nil))
+	if w.Code != 404 {
+		t.Errorf("expected 404, got %v", w.Code)
+	}
+}
+
+func TestTreeMux_RemoveMethod(t *testing.T) {
+	handleFunc := func(w http.ResponseWriter, r *http.Request) {}
+
+	tr := NewTreeMux()
+	tr.HandleMethodFunc(http.MethodGet, "/items", handleFunc)
+	tr.HandleMethodFunc(http.MethodPost, "/items", handleFunc)
+	tr.Group("/plugin").HandleFunc("/a", handleFunc)
+
+	steps := []struct {
+		method string
+		path   string
+		exp1   bool
+		exp2   string
+	}{
+		{http.MethodPut, "/items", false, "GET, POST  /items\n*  /plugin/a\n"},
+		{http.MethodPost, "/items", true, "GET  /items\n*  /plugin/a\n"},
+		{http.MethodPost, "/items", false, "GET  /items\n*  /plugin/a\n"},
+		{http.MethodGet, "items", true, "*  /plugin/a\n"},
+		{"", "/plugin/a", true, ""},
+		{"", "/plugin/a", false, ""},
+	}
+	for i, step := range steps {
+		act := tr.RemoveMethod(step.method, step.path)
+		if act != step.exp1 {
+			t.Errorf("%v: %s %s expected %v, got %v", i, step.method, step.path, step.exp1, act)
+		}
+		b := &strings.Builder{}
+		_ = tr.Walk(func(pattern string, methods []string, _ http.Handler) error {
+			_, _ = fmt.Fprintf(b, "%s  %s\n", strings.Join(methods, ", "), pattern)
+			return nil
+		})
+		if b.String() != step.exp2 {
+			t.Errorf("%v: %s %s expected routes:\n%s\ngot:\n%s", i, step.method, step.path, step.exp2, b.String())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items", nil))
+	if w.Code != 404 {
+		t.Errorf("expected 404, got %v", w.Code)
+	}
+}
+
+type discardWriter struct {
+	h http.Header
+}
+
+func (w *discardWriter) Header() http.Header         { return w.h }
+func (w *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardWriter) WriteHeader(int)             {}
+
+func benchmarkRouting(b *testing.B, h http.Handler, path string) {
+	w := &discardWriter{h: http.Header{}}
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 1 {
+		h.ServeHTTP(w, r)
+	}
+}
+
+func TestTreeMux_ServeHTTP_NoAllocs(t *testing.T) {
+	var pattern string
+	tr := NewTreeMux()
+	tr.HandleFunc("/api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		pattern = RoutePattern(r)
+	})
+	w := &discardWriter{h: http.Header{}}
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/users", nil)
+	// only the writer tracking whether the response has started
+	if n := testing.AllocsPerRun(100, func() { tr.ServeHTTP(w, r) }); n != 1 {
+		t.Errorf("expected one allocation for a static route, got %v", n)
+	}
+	if pattern != "/api/v1/users" {
+		t.Errorf("expected pattern /api/v1/users, got %q", pattern)
+	}
+}
+
+func BenchmarkRouting(b *testing.B) {
+	noop := func(w http.ResponseWriter, r *http.Request) {}
+	for _, n := range []int{10, 100, 1000} {
+		path := fmt.Sprintf("/api/v1/res%v/items/detail", n-1)
+
+		tr := NewTreeMux()
+		sm := http.NewServeMux()
+		for i := 0; i < n; i += 1 {
+			tr.HandleFunc(fmt.Sprintf("/api/v1/res%v/items/detail", i), noop)
+			sm.HandleFunc(fmt.Sprintf("/api/v1/res%v/items/detail", i), noop)
+		}
+		b.Run(fmt.Sprintf("TreeMux/static/%v", n), func(b *testing.B) {
+			benchmarkRouting(b, tr, path)
+		})
+		b.Run(fmt.Sprintf("ServeMux/static/%v", n), func(b *testing.B) {
+			benchmarkRouting(b, sm, path)
+		})
+
+		tr = NewTreeMux()
+		for i := 0; i < n; i += 1 {
+			tr.HandleFunc(fmt.Sprintf("/api/v1/res%v/{id}/detail", i), noop)
+		}
+		b.Run(fmt.Sprintf("TreeMux/param/%v", n), func(b *testing.B) {
+			benchmarkRouting(b, tr, fmt.Sprintf("/api/v1/res%v/42/detail", n-1))
+		})
+	}
+}
+
+func TestTreeMux_SetCleanPath(t *testing.T) {
+	handleFunc := func(w http.ResponseWriter, r *http.Request) {}
+
+	tr := NewTreeMux()
+	tr.HandleFunc("/a/c", handleFunc)
+	tr.SetCleanPath(true)
+
+	cases := []struct {
+		method   string
+		target   string
+		code     int
+		location string
+	}{
+		{http.MethodGet, "/a/c", 200, ""},
+		{http.MethodGet, "/a//b/../c", 301, "/a/c"},
+		{http.MethodHead, "/./a/c", 301, "/a/c"},
+		{http.MethodPost, "/a/./c?x=1", 308, "/a/c?x=1"},
+		{http.MethodGet, "//a/c/", 301, "/a/c/"},
+		{http.MethodOptions, "*", 404, ""},
+		{http.MethodConnect, "example.com:443", 404, ""},
+	}
+	for i, c := range cases {
+		w := httptest.NewRecorder()
+		tr.ServeHTTP(w, httptest.NewRequest(c.method, c.target, nil))
+		if w.Code != c.code || w.Header().Get("Location") != c.location {
+			t.Errorf("%v %s: expected %v %s, got %v %s", i, c.target, c.code, c.location, w.Code, w.Header().Get("Location"))
+		}
+	}
+
+	tr.SetCleanPath(false)
+	w := httptest.NewRecorder()
+	tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/a//b/../c", nil))
+	if w.Code != 404 {
+		t.Errorf("expected 404, got %v", w.Code)
+	}
+}
+
+func TestTreeMux_SetTrailingSlash(t *testing.T) {
+	handler := func(s string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(s))
+		}
+	}
+
+	tr := NewTreeMux()
+	tr.HandleFunc("/a", handler("a"))
+	tr.HandleFunc("/b/", handler("b/"))
+	tr.HandleFunc("/c", handler("c"))
+	tr.HandleFunc("/c/", handler("c/"))
+	tr.HandleFunc("/users", handler("users"))
+	tr.HandleFunc("/users/{id}", handler("user"))
+
+	cases := []struct {
+		policy   TrailingSlash
+		method   string
+		target   string
+		code     int
+		body     string
+		location string
+	}{
+		{TrailingSlashStrict, http.MethodGet, "/a", 200, "a", ""},
+		{TrailingSlashStrict, http.MethodGet, "/a/", 404, "", ""},
+		{TrailingSlashStrict, http.MethodGet, "/b", 404, "", ""},
+		{TrailingSlashStrict, http.MethodGet, "/users/", 404, "", ""},
+		{TrailingSlashRedirect, http.MethodGet, "/a/", 301, "", "/a"},
+		{TrailingSlashRedirect, http.MethodGet, "/b?x=1", 301, "", "/b/?x=1"},
+		{TrailingSlashRedirect, http.MethodPost, "/users/42/", 308, "", "/users/42"},
+		{TrailingSlashRedirect, http.MethodGet, "/users/", 301, "", "/users"},
+		{TrailingSlashRedirect, http.MethodGet, "/c/", 200, "c/", ""},
+		{TrailingSlashRedirect, http.MethodGet, "/d/", 404, "", ""},
+		{TrailingSlashEquivalent, http.MethodGet, "/a/", 200, "a", ""},
+		{TrailingSlashEquivalent, http.MethodGet, "/b", 200, "b/", ""},
+		{TrailingSlashEquivalent, http.MethodGet, "/c", 200, "c", ""},
+		{TrailingSlashEquivalent, http.MethodGet, "/users/42/", 200, "user", ""},
+		{TrailingSlashEquivalent, http.MethodGet, "/users/", 200, "users", ""},
+		{TrailingSlashEquivalent, http.MethodGet, "/d", 404, "", ""},
+	}
+	for i, c := range cases {
+		tr.Group("/x").SetTrailingSlash(c.policy)
+		w := httptest.NewRecorder()
+		tr.ServeHTTP(w, httptest.NewRequest(c.method, c.target, nil))
+		if w.Code != c.code || w.Header().Get("Location") != c.location {
+			t.Errorf("%v %s: expected %v %s, got %v %s", i, c.target, c.code, c.location, w.Code, w.Header().Get("Location"))
+			continue
+		}
+		if c.code == 200 && w.Body.String() != c.body {
+			t.Errorf("%v %s: expected %s, got %s", i, c.target, c.body, w.Body.String())
+		}
+	}
+}
+
+func TestTreeMux_ErrorHandlers(t *testing.T) {
+	outcome := func(w http.ResponseWriter, r *http.Request, code int) {
+		o, ok := RouteOutcome(r)
+		w.WriteHeader(code)
+		_, _ = fmt.Fprintf(w, "%s|%s|%s|%v", o.Pattern, o.Prefix, strings.Join(o.Allowed, ","), ok)
+	}
+
+	tr := NewTreeMux()
+	tr.HandleMethodFunc(http.MethodGet, "/items/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	tr.HandleFunc("/panic/{id}", func(w http.ResponseWriter, r *http.Request) {
+		panic("oops " + PathParam(r, "id"))
+	})
+	tr.SetNotFound(func(w http.ResponseWriter, r *http.Request) {
+		outcome(w, r, http.StatusNotFound)
+	})
+	api := tr.Group("/api")
+	api.SetNotFound(func(w http.ResponseWriter, r *http.Request) {
+		outcome(w, r, http.StatusGone)
+	})
+	api.HandleFunc("/abort", func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+	api.HandleFunc("/partial", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("partial"))
+		panic("oops")
+	})
+	api.SetMethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		outcome(w, r, http.StatusMethodNotAllowed)
+	})
+	api.SetPanicHandler(func(w http.ResponseWriter, r *http.Request, v interface{}) {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = fmt.Fprintf(w, "%v|", v)
+		o, _ := RouteOutcome(r)
+		_, _ = fmt.Fprintf(w, "%s|%s", o.Pattern, o.Prefix)
+	})
+
+	cases := []struct {
+		method string
+		path   string
+		code   int
+		body   string
+	}{
+		{http.MethodGet, "/nope", 404, "|/||true"},
+		{http.MethodGet, "/api/nope", 410, "|/api/||true"},
+		{http.MethodPost, "/items/1", 405, "/items/{id}||GET,HEAD,OPTIONS|true"},
+		{http.MethodGet, "/panic/1", 500, "oops 1|/panic/{id}|"},
+		{http.MethodGet, "/items/1", 200, ""},
+	}
+	for i, c := range cases {
+		w := httptest.NewRecorder()
+		tr.ServeHTTP(w, httptest.NewRequest(c.method, c.path, nil))
+		if w.Code != c.code || w.Body.String() != c.body {
+			t.Errorf("%v %s %s: expected %v %q, got %v %q", i, c.method, c.path, c.code, c.body, w.Code, w.Body.String())
+		}
+	}
+
+	// panics after the response has started are not passed to the handler
+	for _, p := range []string{"/api/abort", "/api/partial"} {
+		w := httptest.NewRecorder()
+		func() {
+			defer func() {
+				if r := recover(); r != http.ErrAbortHandler {
+					t.Errorf("%s: expected ErrAbortHandler, got %v", p, r)
+				}
+			}()
+			tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, p, nil))
+		}()
+		if strings.Contains(w.Body.String(), "|") {
+			t.Errorf("%s: unexpected panic handler response %q", p, w.Body.String())
+		}
+	}
+
+	tr.SetMethodNotAllowed(nil)
+	tr.SetPanicHandler(nil)
+	for _, c := range []struct {
+		method string
+		path   string
+		code   int
+	}{
+		{http.MethodPost, "/items/1", 405},
+		{http.MethodGet, "/panic/1", 500},
+	} {
+		w := httptest.NewRecorder()
+		tr.ServeHTTP(w, httptest.NewRequest(c.method, c.path, nil))
+		if w.Code != c.code {
+			t.Errorf("%s %s: expected %v, got %v", c.method, c.path, c.code, w.Code)
+		}
+	}
+}
+
+func TestTreeMux_SetStrict(t *testing.T) {
+	handleFunc := func(w http.ResponseWriter, r *http.Request) {}
+
+	tr := NewTreeMux()
+	tr.SetStrict(true)
+	tr.HandleMethodFunc(http.MethodGet, "/a/*/c", handleFunc)
+	tr.HandleMethodFunc(http.MethodPost, "/a/*/c", handleFunc)
+	tr.HandleFunc("/a/b", handleFunc)
+
+	cases := []struct {
+		method string
+		path   string
+		exp    *ConflictError
+	}{
+		{http.MethodGet, "/a/*/c", &ConflictError{"/a/*/c", "/a/*/c", true}},
+		{http.MethodPut, "/a/*/c", nil},
+		{http.MethodGet, "/a/b/*", &ConflictError{"/a/b/*", "/a/*/c", false}},
+		{http.MethodGet, "/a/{x}/c", nil},
+		{http.MethodGet, "/a/b/c", nil},
+		{http.MethodGet, "/a/**", nil},
+	}
+	for i, c := range cases {
+		func() {
+			defer func() {
+				r := recover()
+				if c.exp == nil && r != nil {
+					t.Errorf("%v %s: unexpected panic %v", i, c.path, r)
+				}
+				if c.exp != nil && !reflect.DeepEqual(r, c.exp) {
+					t.Errorf("%v %s: expected panic %v, got %v", i, c.path, c.exp, r)
+				}
+			}()
+			tr.HandleMethodFunc(c.method, c.path, handleFunc)
+		}()
+	}
+
+	tr.SetStrict(false)
+	tr.HandleFunc("/a/b/*", handleFunc)
+	if err := tr.Validate(); err == nil || err.Error() != `route "/a/*/c" conflicts with "/a/b/*"`+"\n"+`route "/a/b/*" conflicts with "/a/{x}/c"` {
+		t.Errorf("expected conflict, got %v", err)
+	}
+}
+
+func TestTreeMux_Validate(t *testing.T) {
+	handleFunc := func(w http.ResponseWriter, r *http.Request) {}
+
+	tr := NewTreeMux()
+	tr.HandleFunc("/a/*/c", handleFunc)
+	tr.HandleFunc("/a/b/*", handleFunc)
+	tr.HandleFunc("/users/{id}", handleFunc)
+	tr.HandleFunc("/users/{uid}", handleFunc)
+	tr.HandleFunc("/users/me", handleFunc)
+	tr.HandleFunc("/static/**", handleFunc)
+
+	err := tr.Validate()
+	var errs []*ConflictError
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		errs = append(errs, e.(*ConflictError))
+	}
+	expected := []*ConflictError{
+		{"/a/*/c", "/a/b/*", false},
+		{"/users/{id}", "/users/{uid}", true},
+	}
+	if !reflect.DeepEqual(errs, expected) {
+		t.Errorf("expected %v, got %v", expected, errs)
+	}
+
+	if err := tr.Group("/static").Validate(); err != nil {
+		t.Errorf("expected no conflicts, got %v", err)
+	}
+	if err := tr.Group("/users").Validate(); err == nil {
+		t.Errorf("expected conflicts")
+	}
+}
@@ -0,0 +1,88 @@
+package http
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/HayoVanLoon/go-commons/pathtrie"
+)
+
+// Fills in the named elements of the pattern with the given key-value pairs and
+// escapes them. Catch-all values may contain separators; their elements are
+// escaped one by one. Values must satisfy the constraints of their elements.
+// For patterns with a host pattern, a network-path reference is returned, like
+// "//api.example.com/v1/items".
+func buildURL(pattern string, params []string) (string, error) {
+	if len(params)%2 != 0 {
+		return "", fmt.Errorf("odd number of parameters for %q", pattern)
+	}
+	vs := make(map[string]string, len(params)/2)
+	for i := 0; i < len(params); i += 2 {
+		if _, ok := vs[params[i]]; ok {
+			return "", fmt.Errorf("duplicate parameter %q for %q", params[i], pattern)
+		}
+		vs[params[i]] = params[i+1]
+	}
+
+	host, path := splitHost(pattern)
+	hs := strings.Split(host, ".")
+	if err := fill(pattern, hs, vs, true); err != nil {
+		return "", err
+	}
+	xs := strings.Split(path, "/")
+	if err := fill(pattern, xs, vs, false); err != nil {
+		return "", err
+	}
+	for k := range vs {
+		return "", fmt.Errorf("unknown parameter %q for %q", k, pattern)
+	}
+	if host != "" {
+		return "//" + strings.Join(hs, ".") + strings.Join(xs, "/"), nil
+	}
+	return strings.Join(xs, "/"), nil
+}
+
+// Replaces the named elements among xs by their values, removing those from
+// vs. Values cannot be empty, except for catch-alls, and values for host
+// labels cannot contain dots or separators.
+func fill(pattern string, xs []string, vs map[string]string, host bool) error {
+	for i, x := range xs {
+		if x == pathtrie.Wildcard || x == pathtrie.CatchAll {
+			return fmt.Errorf("cannot fill in anonymous wildcard in %q", pattern)
+		}
+		name, rest := pathtrie.CatchAllName(x)
+		if !rest {
+			var ok bool
+			if name, ok = pathtrie.ParamName(x); !ok {
+				continue
+			}
+		}
+		v, ok := vs[name]
+		if !ok {
+			return fmt.Errorf("missing parameter %q for %q", name, pattern)
+		}
+		delete(vs, name)
+		if c := pathtrie.ParamConstraint(x); c != "" && !accepts(x, v) {
+			return fmt.Errorf("parameter %q does not satisfy constraint %q for %q", name, c, pattern)
+		}
+		switch {
+		case host:
+			if v == "" || strings.ContainsAny(v, "./") {
+				return fmt.Errorf("invalid host label %q for %q", v, pattern)
+			}
+			xs[i] = v
+		case !rest && v == "":
+			return fmt.Errorf("empty parameter %q for %q", name, pattern)
+		case !rest:
+			xs[i] = url.PathEscape(v)
+		default:
+			ys := strings.Split(v, "/")
+			for j := range ys {
+				ys[j] = url.PathEscape(ys[j])
+			}
+			xs[i] = strings.Join(ys, "/")
+		}
+	}
+	return nil
+}
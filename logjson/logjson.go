@@ -35,9 +35,11 @@ package logjson
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"syscall"
 	"time"
 )
 
@@ -72,6 +74,32 @@ type Logger interface {
 	Emergency(v ...interface{})
 }
 
+// A RequestLogger logs served HTTP requests. The loggers created by NewLogger
+// and NewDefaultLogger are RequestLoggers.
+type RequestLogger interface {
+	// Logs a served HTTP request on INFO level, or on WARNING or ERROR level
+	// for responses with a 4xx or 5xx status. The trace is the ID of the trace
+	// the request is part of, if any. Labels are added to the entry.
+	// See Logger.Debug for argument rules.
+	Request(req HTTPRequest, trace string, labels map[string]string, v ...interface{})
+}
+
+// An HTTPRequest describes a served HTTP request, as Cloud Logging expects it
+// in the httpRequest field of an entry.
+type HTTPRequest struct {
+	RequestMethod string `json:"requestMethod,omitempty"`
+	RequestURL    string `json:"requestUrl,omitempty"`
+	RequestSize   int64  `json:"requestSize,string,omitempty"`
+	Status        int    `json:"status,omitempty"`
+	ResponseSize  int64  `json:"responseSize,string,omitempty"`
+	UserAgent     string `json:"userAgent,omitempty"`
+	RemoteIP      string `json:"remoteIp,omitempty"`
+	Referer       string `json:"referer,omitempty"`
+	// A duration like "0.125s".
+	Latency  string `json:"latency,omitempty"`
+	Protocol string `json:"protocol,omitempty"`
+}
+
 type Severity int
 
 const (
@@ -139,9 +167,11 @@ var instance Logger = NewDefaultLogger("", "")
 
 // Based on https://github.com/GoogleCloudPlatform/golang-samples/blob/master/run/logging-manual/main.go
 type entry struct {
-	Message  interface{} `json:"message"`
-	Severity string      `json:"severity,omitempty"`
-	Trace    string      `json:"logging.googleapis.com/trace,omitempty"` // TODO decide whether or not to implement it
+	Message     interface{}       `json:"message"`
+	Severity    string            `json:"severity,omitempty"`
+	Trace       string            `json:"logging.googleapis.com/trace,omitempty"`
+	HTTPRequest *HTTPRequest      `json:"httpRequest,omitempty"`
+	Labels      map[string]string `json:"logging.googleapis.com/labels,omitempty"`
 	// Stackdriver Log Viewer allows filtering and display of this as `jsonPayload.component`.
 	Component string `json:"component,omitempty"`
 }
@@ -154,8 +184,11 @@ func (e entry) String() string {
 }
 
 func (l logger) log(sev Severity, trace string, v ...interface{}) {
+	l.write(entry{Severity: toName[sev]}, sev, trace, v...)
+}
+
+func (l logger) write(e entry, sev Severity, trace string, v ...interface{}) {
 	// TODO(hvl): check if message can be nested or if we need to add extra fields
-	e := entry{Severity: toName[sev]}
 	if len(v) == 0 {
 		e.Message = "(no message)"
 		fmt.Println(e)
@@ -174,8 +207,10 @@ func (l logger) log(sev Severity, trace string, v ...interface{}) {
 		}
 	}
 
-	if trace != "" {
-		e.Trace = "/projects/" + l.projectId + "/traces/" + trace
+	if trace != "" && l.projectId != "" {
+		e.Trace = "projects/" + l.projectId + "/traces/" + trace
+	} else if trace != "" {
+		e.Trace = trace
 	}
 	if l.component != "" {
 		e.Component = l.component
@@ -227,6 +262,17 @@ func (l logger) Emergency(v ...interface{}) {
 	l.log(LevelEmergency, "", v...)
 }
 
+func (l logger) Request(req HTTPRequest, trace string, labels map[string]string, v ...interface{}) {
+	var sev Severity = LevelInfo
+	switch {
+	case req.Status >= 500:
+		sev = LevelError
+	case req.Status >= 400:
+		sev = LevelWarning
+	}
+	l.write(entry{Severity: toName[sev], HTTPRequest: &req, Labels: labels}, sev, trace, v...)
+}
+
 // Logs a message on DEBUG level.
 // If multiple arguments are passed, the first one should be format string.
 func Debug(v ...interface{}) {
@@ -275,6 +321,12 @@ func Emergency(v ...interface{}) {
 	instance.Emergency(v...)
 }
 
+// Logs a served HTTP request.
+// See RequestLogger.Request for details and Debug for argument rules.
+func Request(req HTTPRequest, trace string, labels map[string]string, v ...interface{}) {
+	instance.(RequestLogger).Request(req, trace, labels, v...)
+}
+
 // Used in defer statements, logs a panic and exits program after a delay.
 // In some environments the runtime environment can be killed before the log
 // message has been safely stored.
@@ -285,3 +337,13 @@ func DelayPanicExit() {
 		os.Exit(1)
 	}
 }
+
+// Flushes written log entries to their destination. Errors for outputs that
+// cannot be synced, like pipes, are ignored.
+func Flush() error {
+	err := os.Stdout.Sync()
+	if errors.Is(err, os.ErrInvalid) || errors.Is(err, syscall.EINVAL) || errors.Is(err, syscall.ENOTSUP) {
+		return nil
+	}
+	return err
+}
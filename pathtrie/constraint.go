@@ -0,0 +1,63 @@
+package pathtrie
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// A Constraint restricts the values a named element ("{name:constraint}")
+// matches. Convert reports whether a value satisfies the constraint and returns
+// it converted to the type the constraint stands for.
+type Constraint interface {
+	Convert(s string) (interface{}, bool)
+}
+
+// A ConstraintFunc is a function that acts as a Constraint.
+type ConstraintFunc func(s string) (interface{}, bool)
+
+func (f ConstraintFunc) Convert(s string) (interface{}, bool) {
+	return f(s)
+}
+
+// The built-in constraints, by name.
+var constraints = map[string]Constraint{
+	// a base 10 integer, converted to int64
+	"int": ConstraintFunc(func(s string) (interface{}, bool) {
+		i, err := strconv.ParseInt(s, 10, 64)
+		return i, err == nil
+	}),
+	// a date like 2006-01-02, converted to time.Time (UTC)
+	"date": ConstraintFunc(func(s string) (interface{}, bool) {
+		d, err := time.Parse("2006-01-02", s)
+		return d, err == nil
+	}),
+}
+
+// Returns the constraint of a named element ("{name:constraint}"), or an empty
+// string if it has none.
+func ParamConstraint(k string) string {
+	_, c, _ := parseParam(k)
+	return c
+}
+
+// Compiles a constraint. Besides the built-in constraints "int" (converting to
+// int64) and "date" (converting 2006-01-02 to time.Time), any regular
+// expression can be used. It must match the value as a whole, which is then
+// returned as is.
+func CompileConstraint(c string) (Constraint, error) {
+	if x, ok := constraints[c]; ok {
+		return x, nil
+	}
+	re, err := regexp.Compile("^(?:" + c + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid constraint %q: %w", c, err)
+	}
+	return ConstraintFunc(func(s string) (interface{}, bool) {
+		if !re.MatchString(s) {
+			return nil, false
+		}
+		return s, true
+	}), nil
+}
@@ -0,0 +1,548 @@
+// Package pathtrie provides a trie for looking up data by path, with support
+// for wildcards, named elements and catch-alls.
+//
+// Paths are broken up into elements by a separator that is passed on every
+// call, so the same trie type can hold URL paths ("/"), config keys (".") or
+// message topics. Wildcards are a retrieval-time concept. Elements are stored
+// as given and only interpreted during retrieval:
+//
+//   "*"         (or any other wildcard passed) matches any single element
+//   "{name}"    matches any single element and binds it to the name
+//   "{name:c}"  like "{name}", for elements that satisfy constraint c
+//   "**"        matches zero or more elements, up to the end of the path
+//   "{name...}" like "**", binding the matched remainder to the name
+package pathtrie
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// The default wildcard, matching any single element.
+	Wildcard = "*"
+	// The anonymous catch-all element.
+	CatchAll = "**"
+)
+
+// A Param is a named path element and the value it was bound to during
+// retrieval. For catch-all elements, Rest is set and the value holds the
+// remainder of the path. For elements with a constraint, Typed holds the value
+// as converted by it.
+type Param struct {
+	Name  string
+	Value string
+	Rest  bool
+	Typed interface{}
+}
+
+// Splits a named element into its name and constraint.
+func parseParam(k string) (string, string, bool) {
+	if len(k) <= 2 || k[0] != '{' || k[len(k)-1] != '}' {
+		return "", "", false
+	}
+	k = k[1 : len(k)-1]
+	if i := strings.IndexByte(k, ':'); i >= 0 {
+		return k[:i], k[i+1:], true
+	}
+	return k, "", true
+}
+
+// Returns the name of a named element ("{name}" or "{name:constraint}") and
+// whether the element actually is one. Named catch-alls ("{name...}") are
+// returned with their trailing dots.
+func ParamName(k string) (string, bool) {
+	name, _, ok := parseParam(k)
+	return name, ok
+}
+
+// Returns the name of a catch-all element ("{name...}" or the anonymous "**")
+// and whether the element actually is one. The anonymous catch-all is named
+// "**".
+func CatchAllName(k string) (string, bool) {
+	if k == CatchAll {
+		return CatchAll, true
+	}
+	if name, c, ok := parseParam(k); ok && c == "" && len(name) > 3 && strings.HasSuffix(name, "...") {
+		return name[:len(name)-3], true
+	}
+	return "", false
+}
+
+// A Trie stores values of type V by path. The zero value is an empty trie,
+// ready to use.
+//
+// Internally, chains of literal elements without data or branches are
+// compressed into a single node and literal children are kept sorted, so they
+// can be looked up by binary search. Retrieval walks the path in place; it
+// only allocates to return bound parameters.
+type Trie[V any] struct {
+	// the elements of the node: a single one, or a run of literal elements
+	// that would otherwise form a chain of nodes with one child each
+	ks  []string
+	v   V
+	set bool
+	// literal children, sorted by their first element
+	children []Trie[V]
+	// named and catch-all children, in sorted order
+	dynamic []Trie[V]
+	// the compiled constraint of a named element
+	constraint Constraint
+}
+
+// Creates a new, empty trie.
+func New[V any]() *Trie[V] {
+	return &Trie[V]{}
+}
+
+// Returns whether the element is a named or catch-all element.
+func isDynamic(k string) bool {
+	_, ok := ParamName(k)
+	return ok || k == CatchAll
+}
+
+// Returns whether the element can be part of a compressed run. Empty elements
+// are excluded, as they have a special role as leaves. See Get.
+func isPlain(k string) bool {
+	return k != "" && !isDynamic(k)
+}
+
+// Splits the path into elements, dropping the leading empty element.
+func split(s, sep string) []string {
+	xs := strings.Split(s, sep)
+	if len(xs) > 0 && xs[0] == "" {
+		return xs[1:]
+	}
+	return xs
+}
+
+// Returns the element starting at pos and the position of the element after
+// it. When there are no more elements, that position lies beyond the end of
+// the path.
+func element(s string, pos int, sep string) (string, int) {
+	if sep == "" {
+		_, n := utf8.DecodeRuneInString(s[pos:])
+		if pos+n == len(s) {
+			return s[pos:], len(s) + 1
+		}
+		return s[pos : pos+n], pos + n
+	}
+	i := strings.Index(s[pos:], sep)
+	if i < 0 {
+		return s[pos:], len(s) + 1
+	}
+	return s[pos : pos+i], pos + i + len(sep)
+}
+
+// Breaks up a string using the specified separator and adds the data to the
+// trie. When a path already exists in the trie, the old data is overwritten.
+// Panics when a named element has an invalid constraint.
+//
+// The first element is always expected to be empty. Therefore following
+// statements are idempotent.
+//   trie.Add("/foo/bar", "/", 1)
+//   trie.Add("foo/bar", "/", 1)
+//
+// The wildcard is a flexible, retrieval-time parameter. It plays no role
+// whatsoever at construction-time. One could even apply different wildcard
+// schemes for different purposes on the same trie.
+// See Get for more details on wildcard behaviour.
+func (t *Trie[V]) Add(s, sep string, v V) {
+	t.grow(split(s, sep), v)
+}
+
+func (t *Trie[V]) grow(xs []string, v V) {
+	if len(xs) == 0 {
+		t.v, t.set = v, true
+		return
+	}
+	if isDynamic(xs[0]) {
+		for i := range t.dynamic {
+			if t.dynamic[i].ks[0] == xs[0] {
+				t.dynamic[i].grow(xs[1:], v)
+				return
+			}
+		}
+		c := Trie[V]{ks: []string{xs[0]}}
+		if x := ParamConstraint(xs[0]); x != "" {
+			var err error
+			if c.constraint, err = CompileConstraint(x); err != nil {
+				panic(err)
+			}
+		}
+		// kept in sorted order, so ties between equally specific elements do
+		// not depend on the order of insertion
+		i := sort.Search(len(t.dynamic), func(i int) bool {
+			return t.dynamic[i].ks[0] > xs[0]
+		})
+		t.dynamic = append(t.dynamic, Trie[V]{})
+		copy(t.dynamic[i+1:], t.dynamic[i:])
+		t.dynamic[i] = c
+		t.dynamic[i].grow(xs[1:], v)
+		return
+	}
+	i, found := t.search(xs[0])
+	if !found {
+		n := 1
+		if isPlain(xs[0]) {
+			for n < len(xs) && isPlain(xs[n]) {
+				n += 1
+			}
+		}
+		c := Trie[V]{ks: append([]string{}, xs[:n]...)}
+		t.children = append(t.children, c)
+		copy(t.children[i+1:], t.children[i:])
+		t.children[i] = c
+		t.children[i].grow(xs[n:], v)
+		return
+	}
+	c := &t.children[i]
+	m := 1
+	for m < len(c.ks) && m < len(xs) && c.ks[m] == xs[m] {
+		m += 1
+	}
+	if m < len(c.ks) {
+		c.split(m)
+	}
+	c.grow(xs[m:], v)
+}
+
+// Splits the node after its first m elements.
+func (t *Trie[V]) split(m int) {
+	tail := *t
+	tail.ks = t.ks[m:]
+	*t = Trie[V]{ks: t.ks[:m:m], children: []Trie[V]{tail}}
+}
+
+// Merges the node with its only child when it holds no data itself, undoing a
+// split.
+func (t *Trie[V]) compact() {
+	if t.set || len(t.children) != 1 || len(t.dynamic) != 0 || !isPlain(t.ks[0]) || !isPlain(t.children[0].ks[0]) {
+		return
+	}
+	c := t.children[0]
+	c.ks = append(t.ks[:len(t.ks):len(t.ks)], c.ks...)
+	*t = c
+}
+
+// Returns the index of the literal child starting with the element, or where
+// it should be inserted, and whether it exists.
+func (t *Trie[V]) search(k string) (int, bool) {
+	lo, hi := 0, len(t.children)
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+		if t.children[mid].ks[0] < k {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo, lo < len(t.children) && t.children[lo].ks[0] == k
+}
+
+// Breaks up a string using the specified separator and removes the data at that
+// exact path from the trie. Like with Add, wildcards play no role; all elements
+// are compared literally. Branches left without any data are pruned.
+//
+// Reports whether there was data to remove.
+func (t *Trie[V]) Delete(s, sep string) bool {
+	return t.shrink(split(s, sep))
+}
+
+func (t *Trie[V]) shrink(xs []string) bool {
+	if len(xs) == 0 {
+		if !t.set {
+			return false
+		}
+		var zero V
+		t.v, t.set = zero, false
+		return true
+	}
+	cs := &t.children
+	i, found := t.search(xs[0])
+	if isDynamic(xs[0]) {
+		cs, found = &t.dynamic, false
+		for i = range t.dynamic {
+			if found = t.dynamic[i].ks[0] == xs[0]; found {
+				break
+			}
+		}
+	}
+	if !found {
+		return false
+	}
+	c := &(*cs)[i]
+	if len(xs) < len(c.ks) {
+		return false
+	}
+	for j := range c.ks {
+		if c.ks[j] != xs[j] {
+			return false
+		}
+	}
+	if !c.shrink(xs[len(c.ks):]) {
+		return false
+	}
+	if !c.set && len(c.children) == 0 && len(c.dynamic) == 0 {
+		*cs = append((*cs)[:i], (*cs)[i+1:]...)
+	} else {
+		c.compact()
+	}
+	return true
+}
+
+// Attempts to retrieve the data from the specified path, split up by the
+// specified separator using the default wildcard "*".
+//
+// When a path has more than one valid end point, the most specific one wins,
+// regardless of insertion order. Per element, a literal match is preferred over
+// a named element with a constraint, that over a named element without one, a
+// named element over a wildcard and a wildcard over a catch-all. When a more
+// specific branch leads nowhere, the next one is tried. A named element only
+// matches when the element satisfies its constraint. Ties between equally
+// specific named elements go to the one whose key sorts first.
+//
+// As a last resort, an empty element without children catches any remaining
+// elements below its parent. Prefer a catch-all for this.
+func (t *Trie[V]) Get(s, sep string) (V, bool) {
+	return t.GetWithWildcard(s, sep, Wildcard)
+}
+
+// Attempts to retrieve the data from the specified path, split up by the
+// specified separator using the specified wildcard.
+//
+// Named elements ("{name}") match any single non-empty element, like the
+// wildcard does for any single element.
+// Catch-all elements ("**" or "{name...}") match zero or more elements, up to
+// the end of the path. Any elements after a catch-all are never reached.
+// Elements that hold no data do not count as an end point.
+func (t *Trie[V]) GetWithWildcard(s, sep, wildcard string) (V, bool) {
+	v, _, found := t.match(s, sep, wildcard, false)
+	return v, found
+}
+
+// Attempts to retrieve the data from the specified path, split up by the
+// specified separator using the default wildcard "*". Also returns the values
+// bound to named and catch-all elements on the way, in path order.
+func (t *Trie[V]) Match(s, sep string) (V, []Param, bool) {
+	return t.MatchWithWildcard(s, sep, Wildcard)
+}
+
+// Like Match, using the specified wildcard.
+func (t *Trie[V]) MatchWithWildcard(s, sep, wildcard string) (V, []Param, bool) {
+	return t.match(s, sep, wildcard, true)
+}
+
+func (t *Trie[V]) match(s, sep, wildcard string, bind bool) (V, []Param, bool) {
+	pos := 0
+	if x, next := element(s, 0, sep); x == "" {
+		pos = next
+	}
+	m := matcher{s: s, sep: sep, wildcard: wildcard, bind: bind}
+	return get(t, &m, pos, nil)
+}
+
+// A matcher holds what stays the same while matching a path.
+type matcher struct {
+	s        string
+	sep      string
+	wildcard string
+	bind     bool
+}
+
+func (m *matcher) param(ps []Param, name, value string, rest bool, typed interface{}) []Param {
+	if !m.bind {
+		return ps
+	}
+	return append(ps, Param{Name: name, Value: value, Rest: rest, Typed: typed})
+}
+
+// Searches the end point for the elements from pos onwards among the
+// descendants of t, all elements of t itself having matched the preceding
+// ones. Children are tried in order of precedence, see Get.
+func get[V any](t *Trie[V], m *matcher, pos int, ps []Param) (V, []Param, bool) {
+	var zero V
+	if pos > len(m.s) {
+		if t.set {
+			return t.v, ps, true
+		}
+		// a catch-all child can still match the (empty) remainder
+		for i := range t.dynamic {
+			if name, ok := CatchAllName(t.dynamic[i].ks[0]); ok && t.dynamic[i].set {
+				return t.dynamic[i].v, m.param(ps, name, "", true, nil), true
+			}
+		}
+		return zero, nil, false
+	}
+	x, next := element(m.s, pos, m.sep)
+	if x != m.wildcard {
+		if i, ok := t.search(x); ok {
+			if v, ps2, found := follow(&t.children[i], m, next, ps); found {
+				return v, ps2, found
+			}
+		}
+	}
+	// named elements with a constraint first
+	for _, constrained := range [2]bool{true, false} {
+		for i := range t.dynamic {
+			c := &t.dynamic[i]
+			name, ok := ParamName(c.ks[0])
+			if !ok || strings.HasSuffix(name, "...") || (c.constraint != nil) != constrained {
+				continue
+			}
+			// without a constraint, a named element only matches non-empty elements
+			if c.constraint == nil && x == "" {
+				continue
+			}
+			var typed interface{}
+			if c.constraint != nil {
+				if typed, ok = c.constraint.Convert(x); !ok {
+					continue
+				}
+			}
+			if v, ps2, found := get(c, m, next, m.param(ps, name, x, false, typed)); found {
+				return v, ps2, found
+			}
+		}
+	}
+	if i, ok := t.search(m.wildcard); ok {
+		if v, ps2, found := follow(&t.children[i], m, next, ps); found {
+			return v, ps2, found
+		}
+	}
+	for i := range t.dynamic {
+		if name, ok := CatchAllName(t.dynamic[i].ks[0]); ok && t.dynamic[i].set {
+			return t.dynamic[i].v, m.param(ps, name, m.s[pos:], true, nil), true
+		}
+	}
+	// an empty leaf catches everything below its parent
+	if i, ok := t.search(""); ok {
+		if c := &t.children[i]; c.set && len(c.children) == 0 && len(c.dynamic) == 0 {
+			return c.v, ps, true
+		}
+	}
+	return zero, nil, false
+}
+
+// Matches the remaining elements of the node, its first element having matched
+// already, and continues the search below it.
+func follow[V any](t *Trie[V], m *matcher, pos int, ps []Param) (V, []Param, bool) {
+	for _, k := range t.ks[1:] {
+		if pos > len(m.s) {
+			var zero V
+			return zero, nil, false
+		}
+		x, next := element(m.s, pos, m.sep)
+		if x != k && k != m.wildcard {
+			var zero V
+			return zero, nil, false
+		}
+		pos = next
+	}
+	return get(t, m, pos, ps)
+}
+
+// Calls fn for every path holding data, joining the elements with the
+// specified separator. Paths are visited depth-first, literal children in
+// sorted order, followed by named and catch-all children in sorted order.
+// As the first element is always empty (see Add), all paths but the root start
+// with the separator: "a.b" is walked as ".a.b". Walking stops at the first
+// error, which is then returned.
+func (t *Trie[V]) Walk(sep string, fn func(path string, v V) error) error {
+	return t.walk(strings.Join(t.ks, sep), sep, fn)
+}
+
+func (t *Trie[V]) walk(path, sep string, fn func(path string, v V) error) error {
+	if t.set {
+		if err := fn(path, t.v); err != nil {
+			return err
+		}
+	}
+	for _, cs := range [][]Trie[V]{t.children, t.dynamic} {
+		for i := range cs {
+			if err := cs[i].walk(path+sep+strings.Join(cs[i].ks, sep), sep, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Returns a copy of the trie that can be modified without affecting the
+// original. The data itself is not copied.
+func (t *Trie[V]) Clone() *Trie[V] {
+	c := *t
+	c.children = cloneAll(t.children)
+	c.dynamic = cloneAll(t.dynamic)
+	return &c
+}
+
+func cloneAll[V any](cs []Trie[V]) []Trie[V] {
+	if cs == nil {
+		return nil
+	}
+	xs := make([]Trie[V], len(cs))
+	for i := range cs {
+		xs[i] = *cs[i].Clone()
+	}
+	return xs
+}
+
+func (t Trie[V]) String() string {
+	b := &strings.Builder{}
+	t.string(b)
+	return b.String()
+}
+
+func (t *Trie[V]) string(b *strings.Builder) {
+	b.WriteRune('{')
+	for i, k := range t.ks {
+		if i > 0 {
+			b.WriteRune(',')
+		}
+		b.WriteString(fmt.Sprintf("%q", k))
+	}
+	if t.set {
+		b.WriteString(fmt.Sprintf("=%v", t.v))
+	}
+	if n := len(t.children) + len(t.dynamic); n > 0 {
+		if len(t.ks) > 0 || t.set {
+			b.WriteRune(',')
+		}
+		b.WriteRune('[')
+		for i, c := range append(append([]Trie[V]{}, t.children...), t.dynamic...) {
+			if i > 0 {
+				b.WriteRune(',')
+			}
+			c.string(b)
+		}
+		b.WriteRune(']')
+	}
+	b.WriteRune('}')
+}
+
+// Reports whether both tries have the same structure and data.
+func Equal[V comparable](t, other *Trie[V]) bool {
+	if len(t.ks) != len(other.ks) || t.set != other.set || t.v != other.v ||
+		len(t.children) != len(other.children) || len(t.dynamic) != len(other.dynamic) {
+		return false
+	}
+	for i := range t.ks {
+		if t.ks[i] != other.ks[i] {
+			return false
+		}
+	}
+	for i := range t.children {
+		if !Equal(&t.children[i], &other.children[i]) {
+			return false
+		}
+	}
+	for i := range t.dynamic {
+		if !Equal(&t.dynamic[i], &other.dynamic[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,623 @@
+package pathtrie
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEqual(t *testing.T) {
+	cases := []struct {
+		left  Trie[int]
+		right Trie[int]
+	}{
+		{
+			left:  Trie[int]{ks: []string{"foo"}},
+			right: Trie[int]{ks: []string{"foo"}},
+		},
+		{
+			left:  Trie[int]{ks: []string{"foo"}, v: 1, set: true},
+			right: Trie[int]{ks: []string{"foo"}, v: 1, set: true},
+		},
+		{
+			left: Trie[int]{ks: []string{"foo"}, v: 1, set: true,
+				children: []Trie[int]{{ks: []string{"bar"}, v: 1, set: true}}},
+			right: Trie[int]{ks: []string{"foo"}, v: 1, set: true,
+				children: []Trie[int]{{ks: []string{"bar"}, v: 1, set: true}}},
+		},
+	}
+
+	for _, c := range cases {
+		if !Equal(&c.left, &c.right) {
+			t.Errorf("expected left == right")
+		}
+		if !Equal(&c.right, &c.left) {
+			t.Errorf("expected right == left")
+		}
+	}
+}
+
+func TestEqual_Not(t *testing.T) {
+	cases := []struct {
+		left  Trie[int]
+		right Trie[int]
+	}{
+		{
+			left:  Trie[int]{ks: []string{"foo"}},
+			right: Trie[int]{ks: []string{"moo"}},
+		},
+		{
+			left:  Trie[int]{ks: []string{"foo"}, v: 1, set: true},
+			right: Trie[int]{ks: []string{"foo"}, v: 2, set: true},
+		},
+		{
+			left:  Trie[int]{ks: []string{"foo"}},
+			right: Trie[int]{ks: []string{"foo"}, v: 1, set: true},
+		},
+		{
+			left: Trie[int]{ks: []string{"foo"}, v: 1, set: true,
+				children: []Trie[int]{{ks: []string{"bar"}, v: 1, set: true}}},
+			right: Trie[int]{ks: []string{"foo"}, v: 1, set: true,
+				children: []Trie[int]{{ks: []string{"bar"}, v: 2, set: true}}},
+		},
+		{
+			left: Trie[int]{ks: []string{"foo"}, v: 1, set: true,
+				children: []Trie[int]{{ks: []string{"bar"}, v: 1, set: true}}},
+			right: Trie[int]{ks: []string{"foo"}, v: 1, set: true,
+				children: []Trie[int]{{ks: []string{"bar"}}}},
+		},
+		{
+			left: Trie[int]{ks: []string{"foo"}, v: 1, set: true,
+				children: []Trie[int]{{ks: []string{"bar"}, v: 1, set: true}}},
+			right: Trie[int]{ks: []string{"foo"}, v: 1, set: true,
+				children: []Trie[int]{{ks: []string{"bar"}, v: 1, set: true}, {ks: []string{"bla"}, v: 1, set: true}}},
+		},
+	}
+
+	for _, c := range cases {
+		if Equal(&c.left, &c.right) {
+			t.Errorf("expected left != right")
+		}
+		if Equal(&c.right, &c.left) {
+			t.Errorf("expected right != left")
+		}
+	}
+}
+
+func TestEqual_Empty(t *testing.T) {
+	left := Trie[int]{}
+	right := Trie[int]{}
+
+	if !Equal(&left, &right) {
+		t.Errorf("expected left == right")
+	}
+	if !Equal(&right, &left) {
+		t.Errorf("expected right == left")
+	}
+}
+
+func TestTrie_Get(t *testing.T) {
+	cases := []struct {
+		input string
+		exp1  int
+		exp2  bool
+	}{
+		{"", -1, true},
+		{"/", 0, false},
+		{"foo", 2, true},
+		{"foo/", 0, false},
+		{"/foo", 2, true},
+		{"/foo/bar", 3, true},
+		{"foo/bar/", 0, false},
+		{"foo/slash", 5, true},
+		{"foo/slash/", 6, true},
+		{"foo/slash/whatever", 6, true},
+		{"meow/woof", 0, false},
+	}
+	tr := Trie[int]{
+		v: -1, set: true,
+		children: []Trie[int]{
+			{ks: []string{"foo"}, v: 2, set: true, children: []Trie[int]{
+				{ks: []string{"bar"}, v: 3, set: true},
+				{ks: []string{"bla"}, v: 4, set: true},
+				{ks: []string{"slash"}, v: 5, set: true, children: []Trie[int]{
+					{ks: []string{""}, v: 6, set: true}}}}},
+			{ks: []string{"meow"}, v: 1, set: true}},
+	}
+
+	for i, c := range cases {
+		act1, act2 := tr.Get(c.input, "/")
+		if act1 != c.exp1 || act2 != c.exp2 {
+			t.Errorf("%v: [%s] expected (%v, %v), got (%v, %v)", i, c.input, c.exp1, c.exp2, act1, act2)
+		}
+	}
+}
+
+func TestTrie_Get_Wildcards(t *testing.T) {
+	cases := []struct {
+		input string
+		exp1  int
+		exp2  bool
+	}{
+		{"", -1, true},
+		{"/", 0, false},
+		{"foo", 2, true},
+		{"foo/", 99, true},
+		{"/foo", 2, true},
+		{"/foo/bar", 3, true},
+		{"foo/bar/", 0, false},
+		{"foo/slash", 5, true},
+		{"foo/slash/", 6, true},
+		{"meow/woof", 0, false},
+	}
+	tr := Trie[int]{
+		v: -1, set: true,
+		children: []Trie[int]{
+			{ks: []string{"foo"}, v: 2, set: true, children: []Trie[int]{
+				{ks: []string{"*"}, v: 99, set: true},
+				{ks: []string{"bar"}, v: 3, set: true},
+				{ks: []string{"slash"}, v: 5, set: true, children: []Trie[int]{
+					{ks: []string{""}, v: 6, set: true}}}}},
+			{ks: []string{"meow"}, v: 1, set: true}},
+	}
+
+	for i, c := range cases {
+		act1, act2 := tr.Get(c.input, "/")
+		if act1 != c.exp1 || act2 != c.exp2 {
+			t.Errorf("%v: [%s] expected (%v, %v), got (%v, %v)", i, c.input, c.exp1, c.exp2, act1, act2)
+		}
+	}
+}
+
+func TestTrie_Add_Happy(t *testing.T) {
+	steps := []struct {
+		input  string
+		input2 int
+		exp2   Trie[int]
+	}{
+		{"foo", 1,
+			Trie[int]{
+				children: []Trie[int]{
+					{ks: []string{"foo"}, v: 1, set: true}}}},
+		{"foo/bar", 2,
+			Trie[int]{
+				children: []Trie[int]{
+					{ks: []string{"foo"}, v: 1, set: true, children: []Trie[int]{
+						{ks: []string{"bar"}, v: 2, set: true}}}}}},
+		{"foo/*", 99,
+			Trie[int]{
+				children: []Trie[int]{
+					{ks: []string{"foo"}, v: 1, set: true, children: []Trie[int]{
+						{ks: []string{"*"}, v: 99, set: true},
+						{ks: []string{"bar"}, v: 2, set: true}}}}}},
+		{"foo/slash/", 6,
+			Trie[int]{
+				children: []Trie[int]{
+					{ks: []string{"foo"}, v: 1, set: true, children: []Trie[int]{
+						{ks: []string{"*"}, v: 99, set: true},
+						{ks: []string{"bar"}, v: 2, set: true},
+						{ks: []string{"slash"}, children: []Trie[int]{
+							{ks: []string{""}, v: 6, set: true}}}}}}}},
+		{"foo/slash", 5,
+			Trie[int]{
+				children: []Trie[int]{
+					{ks: []string{"foo"}, v: 1, set: true, children: []Trie[int]{
+						{ks: []string{"*"}, v: 99, set: true},
+						{ks: []string{"bar"}, v: 2, set: true},
+						{ks: []string{"slash"}, v: 5, set: true, children: []Trie[int]{
+							{ks: []string{""}, v: 6, set: true}}}}}}}},
+		{"/foo/bar", 666,
+			Trie[int]{
+				children: []Trie[int]{
+					{ks: []string{"foo"}, v: 1, set: true, children: []Trie[int]{
+						{ks: []string{"*"}, v: 99, set: true},
+						{ks: []string{"bar"}, v: 666, set: true},
+						{ks: []string{"slash"}, v: 5, set: true, children: []Trie[int]{
+							{ks: []string{""}, v: 6, set: true}}}}}}}},
+	}
+	tr := Trie[int]{}
+	for i, step := range steps {
+		tr.Add(step.input, "/", step.input2)
+
+		if !Equal(&tr, &step.exp2) {
+			t.Errorf("%v: [%s]\nexpected: %s,\ngot:      %s", i, step.input, step.exp2, tr)
+			break
+		}
+	}
+}
+
+func TestTrie_Match(t *testing.T) {
+	cases := []struct {
+		input string
+		exp1  int
+		exp2  []Param
+		exp3  bool
+	}{
+		{"/users", 1, nil, true},
+		{"/users/42", 2, []Param{{"id", "42", false, nil}}, true},
+		{"/users/42/orders", 0, nil, false},
+		{"/users/42/orders/7", 3, []Param{{"id", "42", false, nil}, {"orderId", "7", false, nil}}, true},
+		{"/users/42/orders/7/x", 0, nil, false},
+		{"/users/me", 4, nil, true},
+		{"/users/", 0, nil, false},
+		{"/users//orders/7", 0, nil, false},
+	}
+	tr := Trie[int]{}
+	tr.Add("/users", "/", 1)
+	tr.Add("/users/me", "/", 4)
+	tr.Add("/users/{id}", "/", 2)
+	tr.Add("/users/{id}/orders/{orderId}", "/", 3)
+
+	for i, c := range cases {
+		act1, act2, act3 := tr.Match(c.input, "/")
+		if act1 != c.exp1 || act3 != c.exp3 || !reflect.DeepEqual(act2, c.exp2) {
+			t.Errorf("%v: [%s] expected (%v, %v, %v), got (%v, %v, %v)", i, c.input, c.exp1, c.exp2, c.exp3, act1, act2, act3)
+		}
+	}
+}
+
+func TestTrie_Match_CatchAll(t *testing.T) {
+	cases := []struct {
+		input string
+		exp1  int
+		exp2  []Param
+		exp3  bool
+	}{
+		{"/static", 1, []Param{{"file", "", true, nil}}, true},
+		{"/static/", 1, []Param{{"file", "", true, nil}}, true},
+		{"/static/a.css", 1, []Param{{"file", "a.css", true, nil}}, true},
+		{"/static/css/a.css", 1, []Param{{"file", "css/a.css", true, nil}}, true},
+		{"/proxy", 2, nil, true},
+		{"/proxy/a/b", 3, []Param{{"**", "a/b", true, nil}}, true},
+		{"/users/42/x/y", 4, []Param{{"id", "42", false, nil}, {"**", "x/y", true, nil}}, true},
+		{"/other", 0, nil, false},
+	}
+	tr := Trie[int]{}
+	tr.Add("/static/{file...}", "/", 1)
+	tr.Add("/proxy", "/", 2)
+	tr.Add("/proxy/**", "/", 3)
+	tr.Add("/users/{id}/**", "/", 4)
+
+	for i, c := range cases {
+		act1, act2, act3 := tr.Match(c.input, "/")
+		if act1 != c.exp1 || act3 != c.exp3 || !reflect.DeepEqual(act2, c.exp2) {
+			t.Errorf("%v: [%s] expected (%v, %v, %v), got (%v, %v, %v)", i, c.input, c.exp1, c.exp2, c.exp3, act1, act2, act3)
+		}
+	}
+}
+
+func TestTrie_Match_Constraints(t *testing.T) {
+	date := time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		input string
+		exp1  int
+		exp2  []Param
+		exp3  bool
+	}{
+		{"/orders/42", 1, []Param{{"id", "42", false, int64(42)}}, true},
+		{"/orders/-7", 1, []Param{{"id", "-7", false, int64(-7)}}, true},
+		{"/orders/abc", 2, []Param{{"ref", "abc", false, nil}}, true},
+		{"/orders/latest", 3, nil, true},
+		{"/files/a_b-1", 4, []Param{{"name", "a_b-1", false, "a_b-1"}}, true},
+		{"/files/A.txt", 0, nil, false},
+		{"/at/2024-02-29", 5, []Param{{"date", "2024-02-29", false, date}}, true},
+		{"/at/2023-02-29", 0, nil, false},
+		{"/at/2024-02-29/x", 6, []Param{{"day", "2024-02-29", false, nil}}, true},
+	}
+	tr := Trie[int]{}
+	tr.Add("/orders/{ref}", "/", 2)
+	tr.Add("/orders/{id:int}", "/", 1)
+	tr.Add("/orders/latest", "/", 3)
+	tr.Add("/files/{name:[a-z0-9_-]+}", "/", 4)
+	tr.Add("/at/{date:date}", "/", 5)
+	tr.Add("/at/{day}/x", "/", 6)
+
+	for i, c := range cases {
+		act1, act2, act3 := tr.Match(c.input, "/")
+		if act1 != c.exp1 || act3 != c.exp3 || !reflect.DeepEqual(act2, c.exp2) {
+			t.Errorf("%v: [%s] expected (%v, %v, %v), got (%v, %v, %v)", i, c.input, c.exp1, c.exp2, c.exp3, act1, act2, act3)
+		}
+	}
+}
+
+func TestTrie_Add_InvalidConstraint(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic")
+		}
+	}()
+	tr := Trie[int]{}
+	tr.Add("/files/{name:[a-z}", "/", 1)
+}
+
+func TestTrie_Get_Precedence(t *testing.T) {
+	cases := []struct {
+		input string
+		exp1  int
+		exp2  bool
+	}{
+		{"/a/b", 1, true},
+		{"/a/x", 2, true},
+		{"/a/b/c", 3, true},
+		{"/a/x/c", 3, true},
+		{"/a/b/d", 4, true},
+		{"/a/x/d", 4, true},
+		{"/a/b/e", 5, true},
+		{"/a/x/e/f", 5, true},
+		{"/a", 5, true},
+	}
+	paths := []string{"/a/b", "/a/{x}", "/a/{x}/c", "/a/*/d", "/a/**"}
+
+	// insertion order should not matter
+	for _, order := range [][]int{{0, 1, 2, 3, 4}, {4, 3, 2, 1, 0}, {2, 4, 0, 3, 1}} {
+		tr := Trie[int]{}
+		for _, j := range order {
+			tr.Add(paths[j], "/", j+1)
+		}
+		for i, c := range cases {
+			act1, act2 := tr.Get(c.input, "/")
+			if act1 != c.exp1 || act2 != c.exp2 {
+				t.Errorf("%v %v: [%s] expected (%v, %v), got (%v, %v)", order, i, c.input, c.exp1, c.exp2, act1, act2)
+			}
+		}
+	}
+}
+
+func TestTrie_Get_Ties(t *testing.T) {
+	// equally specific elements: the key sorting first wins, whatever the
+	// insertion order
+	for _, paths := range [][]string{{"/u/{b}", "/u/{a}"}, {"/u/{a}", "/u/{b}"}} {
+		tr := Trie[string]{}
+		for _, p := range paths {
+			tr.Add(p, "/", p)
+		}
+		if act, _ := tr.Get("/u/1", "/"); act != "/u/{a}" {
+			t.Errorf("%v: expected /u/{a}, got %s", paths, act)
+		}
+	}
+}
+
+func TestTrie_Delete(t *testing.T) {
+	steps := []struct {
+		input string
+		exp1  bool
+		exp2  Trie[int]
+	}{
+		{"foo/nope", false,
+			Trie[int]{
+				children: []Trie[int]{
+					{ks: []string{"foo"}, v: 1, set: true, children: []Trie[int]{
+						{ks: []string{"*"}, v: 99, set: true},
+						{ks: []string{"bar"}, v: 2, set: true},
+						{ks: []string{"slash"}, children: []Trie[int]{
+							{ks: []string{""}, v: 6, set: true}}}}}}}},
+		{"foo/slash", false,
+			Trie[int]{
+				children: []Trie[int]{
+					{ks: []string{"foo"}, v: 1, set: true, children: []Trie[int]{
+						{ks: []string{"*"}, v: 99, set: true},
+						{ks: []string{"bar"}, v: 2, set: true},
+						{ks: []string{"slash"}, children: []Trie[int]{
+							{ks: []string{""}, v: 6, set: true}}}}}}}},
+		{"foo/bar", true,
+			Trie[int]{
+				children: []Trie[int]{
+					{ks: []string{"foo"}, v: 1, set: true, children: []Trie[int]{
+						{ks: []string{"*"}, v: 99, set: true},
+						{ks: []string{"slash"}, children: []Trie[int]{
+							{ks: []string{""}, v: 6, set: true}}}}}}}},
+		{"/foo/slash/", true,
+			Trie[int]{
+				children: []Trie[int]{
+					{ks: []string{"foo"}, v: 1, set: true, children: []Trie[int]{
+						{ks: []string{"*"}, v: 99, set: true}}}}}},
+		{"foo", true,
+			Trie[int]{
+				children: []Trie[int]{
+					{ks: []string{"foo", "*"}, v: 99, set: true}}}},
+		{"foo/*", true,
+			Trie[int]{children: []Trie[int]{}}},
+		{"foo/*", false,
+			Trie[int]{children: []Trie[int]{}}},
+	}
+	tr := Trie[int]{}
+	tr.Add("foo", "/", 1)
+	tr.Add("foo/bar", "/", 2)
+	tr.Add("foo/*", "/", 99)
+	tr.Add("foo/slash/", "/", 6)
+	for i, step := range steps {
+		act := tr.Delete(step.input, "/")
+
+		if act != step.exp1 {
+			t.Errorf("%v: [%s] expected %v, got %v", i, step.input, step.exp1, act)
+		}
+		if !Equal(&tr, &step.exp2) {
+			t.Errorf("%v: [%s]\nexpected: %s,\ngot:      %s", i, step.input, step.exp2, tr)
+			break
+		}
+	}
+}
+
+func TestTrie_GetWithWildcard(t *testing.T) {
+	cases := []struct {
+		input string
+		exp1  int
+		exp2  bool
+	}{
+		{"db.primary.host", 1, true},
+		{"db.replica.host", 2, true},
+		{"db.replica.port", 0, false},
+		{"db.*.host", 2, true},
+		{"topics.orders.created.v1", 3, true},
+	}
+	tr := New[int]()
+	tr.Add("db.primary.host", ".", 1)
+	tr.Add("db.?.host", ".", 2)
+	tr.Add("topics.**", ".", 3)
+
+	for i, c := range cases {
+		act1, act2 := tr.GetWithWildcard(c.input, ".", "?")
+		if act1 != c.exp1 || act2 != c.exp2 {
+			t.Errorf("%v: [%s] expected (%v, %v), got (%v, %v)", i, c.input, c.exp1, c.exp2, act1, act2)
+		}
+	}
+}
+
+func TestTrie_Walk(t *testing.T) {
+	tr := New[int]()
+	tr.Add("/foo", "/", 1)
+	tr.Add("/foo/bar", "/", 2)
+	tr.Add("/foo/{id}/**", "/", 3)
+	tr.Add("/moo/", "/", 4)
+	tr.Add("", "/", 5)
+
+	type walked struct {
+		path string
+		v    int
+	}
+	expected := []walked{{"", 5}, {"/foo", 1}, {"/foo/bar", 2}, {"/foo/{id}/**", 3}, {"/moo/", 4}}
+	var actual []walked
+	err := tr.Walk("/", func(path string, v int) error {
+		actual = append(actual, walked{path, v})
+		return nil
+	})
+	if err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+
+	stop := errors.New("stop")
+	count := 0
+	err = tr.Walk("/", func(string, int) error {
+		count += 1
+		return stop
+	})
+	if err != stop || count != 1 {
+		t.Errorf("expected to stop after first error, got %v after %v", err, count)
+	}
+
+	dots := New[int]()
+	dots.Add("a.b", ".", 1)
+	dots.Add(".a.c", ".", 2)
+	actual = nil
+	_ = dots.Walk(".", func(path string, v int) error {
+		actual = append(actual, walked{path, v})
+		return nil
+	})
+	expected = []walked{{".a.b", 1}, {".a.c", 2}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
+
+func TestTrie_Clone(t *testing.T) {
+	tr := New[int]()
+	tr.Add("/foo/bar", "/", 1)
+	c := tr.Clone()
+	c.Add("/foo/moo", "/", 2)
+	c.Delete("/foo/bar", "/")
+
+	if v, ok := tr.Get("/foo/bar", "/"); v != 1 || !ok {
+		t.Errorf("expected original to be untouched, got (%v, %v)", v, ok)
+	}
+	if _, ok := tr.Get("/foo/moo", "/"); ok {
+		t.Errorf("expected original to be untouched")
+	}
+	if v, ok := c.Get("/foo/moo", "/"); v != 2 || !ok {
+		t.Errorf("expected (2, true), got (%v, %v)", v, ok)
+	}
+}
+
+func TestTrie_Add_Compressed(t *testing.T) {
+	steps := []struct {
+		input string
+		exp   Trie[int]
+	}{
+		{"/api/v1/users", Trie[int]{
+			children: []Trie[int]{
+				{ks: []string{"api", "v1", "users"}, v: 1, set: true}}}},
+		{"/api/v1/orders/{id}", Trie[int]{
+			children: []Trie[int]{
+				{ks: []string{"api", "v1"}, children: []Trie[int]{
+					{ks: []string{"orders"}, dynamic: []Trie[int]{
+						{ks: []string{"{id}"}, v: 2, set: true}}},
+					{ks: []string{"users"}, v: 1, set: true}}}}}},
+		{"/api", Trie[int]{
+			children: []Trie[int]{
+				{ks: []string{"api"}, v: 3, set: true, children: []Trie[int]{
+					{ks: []string{"v1"}, children: []Trie[int]{
+						{ks: []string{"orders"}, dynamic: []Trie[int]{
+							{ks: []string{"{id}"}, v: 2, set: true}}},
+						{ks: []string{"users"}, v: 1, set: true}}}}}}}},
+		{"/api/v1/users/me/settings", Trie[int]{
+			children: []Trie[int]{
+				{ks: []string{"api"}, v: 3, set: true, children: []Trie[int]{
+					{ks: []string{"v1"}, children: []Trie[int]{
+						{ks: []string{"orders"}, dynamic: []Trie[int]{
+							{ks: []string{"{id}"}, v: 2, set: true}}},
+						{ks: []string{"users"}, v: 1, set: true, children: []Trie[int]{
+							{ks: []string{"me", "settings"}, v: 4, set: true}}}}}}}}}},
+	}
+	tr := Trie[int]{}
+	for i, step := range steps {
+		tr.Add(step.input, "/", i+1)
+
+		if !Equal(&tr, &step.exp) {
+			t.Errorf("%v: [%s]\nexpected: %s,\ngot:      %s", i, step.input, step.exp, tr)
+			break
+		}
+	}
+
+	// deleting in reverse order should merge the nodes again
+	for i := len(steps) - 1; i > 0; i -= 1 {
+		tr.Delete(steps[i].input, "/")
+
+		if !Equal(&tr, &steps[i-1].exp) {
+			t.Errorf("%v: [%s]\nexpected: %s,\ngot:      %s", i, steps[i].input, steps[i-1].exp, tr)
+			break
+		}
+	}
+}
+
+func TestTrie_Get_Compressed(t *testing.T) {
+	cases := []struct {
+		input string
+		exp1  int
+		exp2  bool
+	}{
+		{"/a/b/c/d", 1, true},
+		{"/a/b/c", 0, false},
+		{"/a/b/x/d", 0, false},
+		{"/a/b/c/d/e", 0, false},
+		{"/x/anything/z", 2, true},
+		{"/x/y/q", 0, false},
+	}
+	tr := New[int]()
+	tr.Add("/a/b/c/d", "/", 1)
+	tr.Add("/x/*/z", "/", 2)
+
+	for i, c := range cases {
+		act1, act2 := tr.Get(c.input, "/")
+		if act1 != c.exp1 || act2 != c.exp2 {
+			t.Errorf("%v: [%s] expected (%v, %v), got (%v, %v)", i, c.input, c.exp1, c.exp2, act1, act2)
+		}
+	}
+}
+
+func TestTrie_Get_NoAllocs(t *testing.T) {
+	tr := New[int]()
+	tr.Add("/api/v1/users/{id}/orders/*", "/", 1)
+	tr.Add("/static/**", "/", 2)
+
+	for _, s := range []string{"/api/v1/users/42/orders/7", "/static/css/a.css", "/nope"} {
+		if n := testing.AllocsPerRun(100, func() { tr.Get(s, "/") }); n != 0 {
+			t.Errorf("[%s] expected no allocations, got %v", s, n)
+		}
+	}
+	if n := testing.AllocsPerRun(100, func() { tr.Match("/api/v1/users", "/") }); n != 0 {
+		t.Errorf("expected no allocations without parameters, got %v", n)
+	}
+}